@@ -0,0 +1,238 @@
+package goava
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// envPrefix is the prefix of every environment variable that overrides
+// a value read by LoadConfig.
+const envPrefix = "GOAVA"
+
+// ConfigError reports every problem found while validating a ClientConfig.
+type ConfigError struct {
+	Errors []error
+}
+
+func (ce *ConfigError) Error() string {
+	msgs := make([]string, len(ce.Errors))
+	for i, err := range ce.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid client config: " + strings.Join(msgs, "; ")
+}
+
+func (ce *ConfigError) Unwrap() []error { return ce.Errors }
+
+// namedConfigs is the layout of a file holding several client configs,
+//
+//	[clients.primary]
+//	dbtype = "cassandra"
+//	[clients.primary.cassandra_config]
+//	server_list = "10.0.0.1,10.0.0.2"
+type namedConfigs struct {
+	Clients map[string]toml.Primitive `toml:"clients"`
+}
+
+// LoadConfig reads a single client config from the TOML file at path.
+// Values missing from the file are taken from DefaultConfig, then GOAVA_*
+// environment variables are applied (for example GOAVA_CASSANDRA_CONFIG_PORT)
+// and the result is validated. Keys the config does not have and overrides
+// that cannot be parsed are reported with the validation problems.
+func LoadConfig(path string) (ClientConfig, error) {
+	conf := DefaultConfig
+	md, err := toml.DecodeFile(path, &conf)
+	if err != nil {
+		return ClientConfig{}, err
+	}
+	problems := unknownKeys(md)
+	problems = append(problems, applyEnv(envPrefix, reflect.ValueOf(&conf).Elem())...)
+	if err := conf.Validate(); err != nil {
+		problems = append(problems, err.(*ConfigError).Errors...)
+	}
+	if len(problems) > 0 {
+		return ClientConfig{}, &ConfigError{problems}
+	}
+	return conf, nil
+}
+
+// unknownKeys returns an error for every key of the file that was not
+// decoded into a config.
+func unknownKeys(md toml.MetaData) []error {
+	var problems []error
+	for _, key := range md.Undecoded() {
+		problems = append(problems, fmt.Errorf("unknown key %s", key))
+	}
+	return problems
+}
+
+// LoadConfigs reads every client config under the [clients.<name>] tables of
+// the TOML file at path. Environment overrides for a named config use the
+// upper cased name after the prefix, e.g. GOAVA_PRIMARY_CASSANDRA_CONFIG_PORT.
+func LoadConfigs(path string) (map[string]ClientConfig, error) {
+	var named namedConfigs
+	md, err := toml.DecodeFile(path, &named)
+	if err != nil {
+		return nil, err
+	}
+
+	// the clients are checked in name order so that the problems are
+	// reported the same way every time
+	names := make([]string, 0, len(named.Clients))
+	for name := range named.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	decoded := make(map[string]ClientConfig, len(names))
+	for _, name := range names {
+		conf := DefaultConfig
+		if err := md.PrimitiveDecode(named.Clients[name], &conf); err != nil {
+			return nil, fmt.Errorf("client %s: %v", name, err)
+		}
+		decoded[name] = conf
+	}
+
+	configs := make(map[string]ClientConfig, len(names))
+	problems := unknownKeys(md)
+	for _, name := range names {
+		conf := decoded[name]
+		prefix := envPrefix + "_" + envName(name)
+		clientProblems := applyEnv(prefix, reflect.ValueOf(&conf).Elem())
+		if err := conf.Validate(); err != nil {
+			clientProblems = append(clientProblems, err.(*ConfigError).Errors...)
+		}
+		if len(clientProblems) > 0 {
+			for _, e := range clientProblems {
+				problems = append(problems, fmt.Errorf("client %s: %v", name, e))
+			}
+			continue
+		}
+		configs[name] = conf
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{problems}
+	}
+	return configs, nil
+}
+
+// LoadNamedConfig reads the client config called name from the TOML file at path.
+func LoadNamedConfig(path string, name string) (ClientConfig, error) {
+	configs, err := LoadConfigs(path)
+	if err != nil {
+		return ClientConfig{}, err
+	}
+	conf, ok := configs[name]
+	if !ok {
+		return ClientConfig{}, fmt.Errorf("no client config named %s in %s", name, path)
+	}
+	return conf, nil
+}
+
+// Validate checks the config and returns a *ConfigError listing every problem found.
+func (conf ClientConfig) Validate() error {
+	var problems []error
+	switch conf.DBType {
+	case DBTypeCass:
+		if strings.TrimSpace(conf.CassandraConfig.ServerList) == "" {
+			problems = append(problems, fmt.Errorf("cassandra_config.server_list is empty"))
+		}
+		if !validPort(conf.CassandraConfig.Port) {
+			problems = append(problems, fmt.Errorf("cassandra_config.port %d out of range", conf.CassandraConfig.Port))
+		}
+	case DBTypeMSQL:
+		if strings.TrimSpace(conf.MySQLConfig.DBServer) == "" {
+			problems = append(problems, fmt.Errorf("mysql_config.db_server_name is empty"))
+		}
+		if !validPort(conf.MySQLConfig.Port) {
+			problems = append(problems, fmt.Errorf("mysql_config.port %d out of range", conf.MySQLConfig.Port))
+		}
+	case DBTypeMongo, DBTypeRedis:
+	default:
+		problems = append(problems, fmt.Errorf("unknown dbtype %q", conf.DBType))
+	}
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func envName(s string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(s))
+}
+
+// applyEnv walks the toml tagged fields of v and overrides each one that has
+// a matching environment variable, named prefix_TAG for nested tables. It
+// returns an error for every variable that could not be applied.
+func applyEnv(prefix string, v reflect.Value) []error {
+	var problems []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name := prefix + "_" + envName(tag)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			problems = append(problems, applyEnv(name, fv)...)
+			continue
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(fv, val); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	return problems
+}
+
+func setFromString(fv reflect.Value, val string) error {
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
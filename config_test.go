@@ -0,0 +1,161 @@
+package goava
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "goava.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+dbtype = "cassandra"
+
+[cassandra_config]
+server_list = "10.0.0.1,10.0.0.2"
+keyspace = "users"
+consistency = "local_quorum"
+timeout = "2s"
+
+[cassandra_config.retry_policy]
+type = "exponential"
+num_retries = 3
+`)
+	t.Setenv("GOAVA_CASSANDRA_CONFIG_PORT", "9142")
+
+	conf, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1,10.0.0.2", conf.CassandraConfig.ServerList)
+	assert.Equal(t, 9142, conf.CassandraConfig.Port)
+	assert.Equal(t, "users", conf.CassandraConfig.KeySpace)
+	assert.Equal(t, 2*time.Second, conf.CassandraConfig.Timeout)
+	assert.Equal(t, "exponential", conf.CassandraConfig.RetryPolicy.Type)
+	assert.Equal(t, DefaultConfig.CassandraConfig.NumConns, conf.CassandraConfig.NumConns)
+}
+
+func TestLoadConfigs(t *testing.T) {
+	path := writeConfig(t, `
+[clients.primary]
+dbtype = "cassandra"
+[clients.primary.cassandra_config]
+keyspace = "primary"
+
+[clients.reporting]
+dbtype = "cassandra"
+[clients.reporting.cassandra_config]
+keyspace = "reporting"
+`)
+	t.Setenv("GOAVA_REPORTING_CASSANDRA_CONFIG_SERVER_LIST", "reporting-db")
+
+	configs, err := LoadConfigs(path)
+	assert.Nil(t, err)
+	assert.Len(t, configs, 2)
+	assert.Equal(t, "primary", configs["primary"].CassandraConfig.KeySpace)
+	assert.Equal(t, "localhost", configs["primary"].CassandraConfig.ServerList)
+	assert.Equal(t, "reporting-db", configs["reporting"].CassandraConfig.ServerList)
+
+	conf, err := LoadNamedConfig(path, "reporting")
+	assert.Nil(t, err)
+	assert.Equal(t, "reporting", conf.CassandraConfig.KeySpace)
+}
+
+func TestValidateAggregatesErrors(t *testing.T) {
+	conf := DefaultConfig
+	conf.DBType = "oracle"
+	err := conf.Validate()
+	var ce *ConfigError
+	assert.True(t, errors.As(err, &ce))
+	assert.Len(t, ce.Errors, 1)
+
+	conf.DBType = DBTypeCass
+	conf.CassandraConfig.ServerList = " "
+	conf.CassandraConfig.Port = 70000
+	err = conf.Validate()
+	assert.True(t, errors.As(err, &ce))
+	assert.Len(t, ce.Errors, 2)
+}
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	path := writeConfig(t, `
+dbtype = "cassandra"
+[cassandra_config]
+server_list = "10.0.0.1"
+prot = 9142
+`)
+	_, err := LoadConfig(path)
+	var ce *ConfigError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, "invalid client config: unknown key cassandra_config.prot", err.Error())
+
+	path = writeConfig(t, `
+[clients.primary]
+dbtype = "cassandra"
+[clients.primary.cassandra_config]
+keyspace = "primary"
+key_space = "primary"
+`)
+	_, err = LoadConfigs(path)
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, "invalid client config: unknown key clients.primary.cassandra_config.key_space", err.Error())
+}
+
+func TestLoadConfigsErrorOrder(t *testing.T) {
+	path := writeConfig(t, `
+[clients.c]
+dbtype = "c"
+[clients.a]
+dbtype = "a"
+[clients.b]
+dbtype = "b"
+`)
+	for i := 0; i < 10; i++ {
+		_, err := LoadConfigs(path)
+		var ce *ConfigError
+		assert.True(t, errors.As(err, &ce))
+		if assert.Len(t, ce.Errors, 3) {
+			assert.Contains(t, ce.Errors[0].Error(), "client a:")
+			assert.Contains(t, ce.Errors[1].Error(), "client b:")
+			assert.Contains(t, ce.Errors[2].Error(), "client c:")
+		}
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+dbtype = "cassandra"
+[cassandra_config]
+server_list = ""
+prot = 9142
+`)
+	t.Setenv("GOAVA_CASSANDRA_CONFIG_PORT", "ninety")
+	t.Setenv("GOAVA_CASSANDRA_CONFIG_TIMEOUT", "soon")
+	_, err := LoadConfig(path)
+	var ce *ConfigError
+	assert.True(t, errors.As(err, &ce))
+	assert.Len(t, ce.Errors, 4, err)
+	assert.Contains(t, err.Error(), "unknown key cassandra_config.prot")
+	assert.Contains(t, err.Error(), "GOAVA_CASSANDRA_CONFIG_PORT")
+	assert.Contains(t, err.Error(), "GOAVA_CASSANDRA_CONFIG_TIMEOUT")
+	assert.Contains(t, err.Error(), "server_list is empty")
+
+	path = writeConfig(t, `
+[clients.primary]
+dbtype = "cassandra"
+`)
+	t.Setenv("GOAVA_PRIMARY_CASSANDRA_CONFIG_PORT", "ninety")
+	_, err = LoadConfigs(path)
+	assert.True(t, errors.As(err, &ce))
+	assert.Len(t, ce.Errors, 1, err)
+	assert.Contains(t, err.Error(), "client primary: GOAVA_PRIMARY_CASSANDRA_CONFIG_PORT")
+}
@@ -0,0 +1,135 @@
+package goava
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/meooio/goava/driver/cassandradb"
+	"github.com/meooio/goava/ops"
+)
+
+// CopyOptions controls a CopyTable.
+type CopyOptions struct {
+	// Table is the name of the destination table, defaults to the name of
+	// the source table.
+	Table string
+	// Model is the data model of the destination table, defaults to the
+	// data model of the source table. Transform must return rows of Model
+	// when it is set.
+	Model interface{}
+	// Filter, when set, copies only the rows it returns true for.
+	Filter func(row interface{}) bool
+	// Transform, when set, returns the row to insert for a row of the
+	// source. A nil row is not copied, an error stops the copy.
+	Transform func(row interface{}) (interface{}, error)
+	// Concurrency is the number of rows read and inserted at the same
+	// time, defaults to 4.
+	Concurrency int
+	// Job and Checkpoint make the copy resumable: a copy started again
+	// with the same job skips the token ranges a previous run completed.
+	// Only cassandra source tables can be resumed.
+	Job        string
+	Checkpoint cassandradb.Checkpoint
+}
+
+// CopyReport summarizes a CopyTable.
+type CopyReport struct {
+	// Rows is the number of rows read from the source
+	Rows int64
+	// Copied is the number of rows inserted into the destination
+	Copied int64
+	// Skipped counts the rows left out by Filter or Transform
+	Skipped  int64
+	Duration time.Duration
+}
+
+// CopyTable copies every row of src into a table of dst. The destination
+// table is created from the data model of the source, an existing table of
+// the same name is written to. src must implement ops.Scanner. Rows are
+// streamed from the source, filtered and transformed, then inserted one by
+// one; the first error stops the copy. Rows are inserted, not merged, so a
+// row that already exists in the destination is overwritten.
+func CopyTable(src ops.Table, dst ops.Database, opts CopyOptions) (CopyReport, error) {
+	start := time.Now()
+	var report CopyReport
+	scanner, ok := src.(ops.Scanner)
+	if !ok {
+		return report, &ops.OpError{Op: ops.OpCopy, Kind: ops.ErrNoSupport,
+			Err: errors.New("source table cannot be scanned")}
+	}
+	name := opts.Table
+	if name == "" {
+		name = scanner.TableName()
+	}
+	model := opts.Model
+	if model == nil {
+		model = scanner.DataModel()
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 4
+	}
+	resume, _ := src.(*cassandradb.Table)
+	if opts.Checkpoint != nil && resume == nil {
+		return report, ops.NewOpError(ops.OpCopy, "", name, ops.ErrNoSupport, "source table cannot be resumed")
+	}
+
+	table, err := dst.CreateTable(name, model)
+	if errors.Is(err, ops.ErrTableExist) {
+		if table == nil {
+			table, err = dst.GetTable(name)
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return report, err
+	}
+
+	var mu sync.Mutex
+	copyRow := func(row interface{}) error {
+		mu.Lock()
+		report.Rows++
+		mu.Unlock()
+		if opts.Filter != nil && !opts.Filter(row) {
+			mu.Lock()
+			report.Skipped++
+			mu.Unlock()
+			return nil
+		}
+		if opts.Transform != nil {
+			var err error
+			if row, err = opts.Transform(row); err != nil {
+				return err
+			}
+			if row == nil {
+				mu.Lock()
+				report.Skipped++
+				mu.Unlock()
+				return nil
+			}
+		}
+		if err := table.Insert(row); err != nil {
+			return err
+		}
+		mu.Lock()
+		report.Copied++
+		mu.Unlock()
+		return nil
+	}
+
+	if opts.Checkpoint != nil {
+		_, err = resume.Backfill(context.Background(), cassandradb.BackfillOptions{
+			Job:        opts.Job,
+			Checkpoint: opts.Checkpoint,
+			Scan:       cassandradb.ScanOptions{Parallelism: opts.Concurrency, Retries: 3, RetryBackoff: 100 * time.Millisecond},
+		}, func(ctx context.Context, row interface{}) error {
+			return copyRow(row)
+		})
+	} else {
+		err = scanner.Scan(opts.Concurrency, copyRow)
+	}
+	report.Duration = time.Since(start)
+	return report, err
+}
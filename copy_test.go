@@ -0,0 +1,194 @@
+package goava
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/meooio/goava/driver/cassandradb"
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type copiedUser struct {
+	ID   string `cql:"column_name=id,primary_key=0"`
+	Name string `cql:"column_name=name"`
+	Age  int    `cql:"column_name=age"`
+}
+
+// memTable keeps its rows in memory, it only supports Insert and Scan.
+type memTable struct {
+	name  string
+	model interface{}
+	mu    sync.Mutex
+	rows  []interface{}
+	fail  error
+}
+
+func (m *memTable) TableName() string      { return m.name }
+func (m *memTable) DataModel() interface{} { return m.model }
+
+func (m *memTable) Scan(parallelism int, fn func(row interface{}) error) error {
+	for _, row := range m.rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memTable) Insert(data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	m.rows = append(m.rows, data)
+	return nil
+}
+
+func (m *memTable) Delete(deleteColumnList []string, whereClause []whc.WhereClauseType) error {
+	return ops.ErrNoSupport
+}
+
+func (m *memTable) ReadAndBind(x interface{}, whereClause []whc.WhereClauseType, groupByClause []string,
+	orderByClause map[string]string) error {
+	return ops.ErrNoSupport
+}
+
+func (m *memTable) Read(whereClause []whc.WhereClauseType, groupByClause []string,
+	orderByClause map[string]string) (interface{}, error) {
+	return nil, ops.ErrNoSupport
+}
+
+func (m *memTable) List(whereClause []whc.WhereClauseType, groupByClause []string,
+	orderByClause map[string]string, count int, pageIndex string) (interface{}, error) {
+	return nil, ops.ErrNoSupport
+}
+
+func (m *memTable) Update(data interface{}) error { return ops.ErrNoSupport }
+
+func (m *memTable) UpdateFields(updateMap, updateParm map[string]interface{},
+	whereClause []whc.WhereClauseType) error {
+	return ops.ErrNoSupport
+}
+
+func (m *memTable) Backup(tableName string) error  { return ops.ErrNoSupport }
+func (m *memTable) Restore(tableName string) error { return ops.ErrNoSupport }
+
+// memDatabase holds memTables.
+type memDatabase struct {
+	tables map[string]*memTable
+}
+
+func (d *memDatabase) DoesTableExist(keySpace string, tableName string) (bool, error) {
+	_, ok := d.tables[tableName]
+	return ok, nil
+}
+
+func (d *memDatabase) CreateTable(tableName string, tableModel interface{}) (ops.Table, error) {
+	if t, ok := d.tables[tableName]; ok {
+		return t, ops.ErrTableExist
+	}
+	t := &memTable{name: tableName, model: tableModel}
+	d.tables[tableName] = t
+	return t, nil
+}
+
+func (d *memDatabase) GetTable(tableName string) (ops.Table, error) {
+	if t, ok := d.tables[tableName]; ok {
+		return t, nil
+	}
+	return nil, ops.ErrTableNA
+}
+
+func (d *memDatabase) DropTable(tableName string) error  { return ops.ErrNoSupport }
+func (d *memDatabase) AlterTable(tableName string) error { return ops.ErrNoSupport }
+func (d *memDatabase) BackupDB(name string) error        { return ops.ErrNoSupport }
+func (d *memDatabase) RestoreDB(name string) error       { return ops.ErrNoSupport }
+
+func copiedUsers() *memTable {
+	return &memTable{name: "users", model: copiedUser{}, rows: []interface{}{
+		copiedUser{ID: "a", Name: "ann", Age: 31},
+		copiedUser{ID: "b", Name: "bob", Age: 17},
+		copiedUser{ID: "c", Name: "cid", Age: 45},
+	}}
+}
+
+func names(rows []interface{}) []string {
+	var out []string
+	for _, row := range rows {
+		out = append(out, row.(copiedUser).Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestCopyTable(t *testing.T) {
+	dst := &memDatabase{tables: map[string]*memTable{}}
+	report, err := CopyTable(copiedUsers(), dst, CopyOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, report.Rows)
+	assert.EqualValues(t, 3, report.Copied)
+
+	copied := dst.tables["users"]
+	require.NotNil(t, copied)
+	assert.Equal(t, copiedUser{}, copied.model)
+	assert.Equal(t, []string{"ann", "bob", "cid"}, names(copied.rows))
+}
+
+func TestCopyTableFilterAndTransform(t *testing.T) {
+	dst := &memDatabase{tables: map[string]*memTable{}}
+	report, err := CopyTable(copiedUsers(), dst, CopyOptions{
+		Table:  "adults",
+		Filter: func(row interface{}) bool { return row.(copiedUser).Age >= 18 },
+		Transform: func(row interface{}) (interface{}, error) {
+			u := row.(copiedUser)
+			if u.ID == "c" {
+				return nil, nil
+			}
+			u.Name += "!"
+			return u, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, report.Rows)
+	assert.EqualValues(t, 1, report.Copied)
+	assert.EqualValues(t, 2, report.Skipped)
+	assert.Equal(t, []string{"ann!"}, names(dst.tables["adults"].rows))
+}
+
+func TestCopyTableIntoExistingTable(t *testing.T) {
+	existing := &memTable{name: "users", model: copiedUser{},
+		rows: []interface{}{copiedUser{ID: "z", Name: "zoe"}}}
+	dst := &memDatabase{tables: map[string]*memTable{"users": existing}}
+	_, err := CopyTable(copiedUsers(), dst, CopyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ann", "bob", "cid", "zoe"}, names(existing.rows))
+}
+
+func TestCopyTableErrors(t *testing.T) {
+	dst := &memDatabase{tables: map[string]*memTable{}}
+	errInsert := errors.New("insert failed")
+	dst.tables["users"] = &memTable{name: "users", fail: errInsert}
+	report, err := CopyTable(copiedUsers(), dst, CopyOptions{})
+	assert.ErrorIs(t, err, errInsert)
+	assert.EqualValues(t, 0, report.Copied)
+
+	errTransform := errors.New("transform failed")
+	_, err = CopyTable(copiedUsers(), dst, CopyOptions{Table: "other",
+		Transform: func(row interface{}) (interface{}, error) { return nil, errTransform }})
+	assert.ErrorIs(t, err, errTransform)
+
+	// a source that cannot be scanned or resumed
+	var src ops.Table = struct{ ops.Table }{}
+	_, err = CopyTable(src, dst, CopyOptions{})
+	assert.ErrorIs(t, err, ops.ErrNoSupport)
+	checkpoint := cassandradb.NewFileCheckpoint(filepath.Join(t.TempDir(), "copy.json"))
+	_, err = CopyTable(copiedUsers(), dst, CopyOptions{Job: "copy", Checkpoint: checkpoint})
+	assert.ErrorIs(t, err, ops.ErrNoSupport)
+}
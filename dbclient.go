@@ -3,13 +3,11 @@ package goava
 import (
 	"github.com/meooio/goava/driver/cassandradb"
 	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type CassDBConfig struct {
-	ServerList string `toml:"server_list"`
-	Port       int    `toml:"port"`
-	KeySpace   string `toml:"keyspace"`
-}
+// CassDBConfig holds the cassandra connection, consistency and retry settings.
+type CassDBConfig = cassandradb.Config
 
 // just as n example
 type MySQLDBConfig struct {
@@ -22,6 +20,17 @@ type ClientConfig struct {
 	DBType          string        `toml:"dbtype"`
 	CassandraConfig CassDBConfig  `toml:"cassandra_config"`
 	MySQLConfig     MySQLDBConfig `toml:"mysql_config"`
+	// Logger receives the driver log entries, nothing is logged when unset.
+	Logger ops.Logger `toml:"-"`
+	// QueryHooks are called around every statement the client runs.
+	QueryHooks []ops.QueryHook `toml:"-"`
+	// Metrics receives query and connection metrics, see the metrics package.
+	Metrics ops.Metrics `toml:"-"`
+	// TracerProvider creates an OpenTelemetry span for every operation.
+	TracerProvider trace.TracerProvider `toml:"-"`
+	// KeyProvider supplies the keys of the columns tagged encrypt, see
+	// cassandradb.KeyProvider.
+	KeyProvider cassandradb.KeyProvider `toml:"-"`
 }
 
 type Client struct {
@@ -31,9 +40,17 @@ type Client struct {
 var DefaultConfig = ClientConfig{
 	DBType: DBTypeCass,
 	CassandraConfig: CassDBConfig{
-		ServerList: "localhost",
-		Port:       9042,
-		KeySpace:   "TestKeySpace",
+		ServerList:        "localhost",
+		Port:              9042,
+		KeySpace:          "TestKeySpace",
+		Consistency:       cassandradb.DefaultConfig.Consistency,
+		ConnectTimeout:    cassandradb.DefaultConfig.ConnectTimeout,
+		Timeout:           cassandradb.DefaultConfig.Timeout,
+		NumConns:          cassandradb.DefaultConfig.NumConns,
+		RetryPolicy:       cassandradb.DefaultConfig.RetryPolicy,
+		ReconnectInterval: cassandradb.DefaultConfig.ReconnectInterval,
+		HostSelection:     cassandradb.DefaultConfig.HostSelection,
+		Supervisor:        cassandradb.DefaultConfig.Supervisor,
 	},
 	MySQLConfig: MySQLDBConfig{},
 }
@@ -42,8 +59,22 @@ var DefaultConfig = ClientConfig{
 func NewDBClient(conf ClientConfig) (DBClient, error) {
 	switch conf.DBType {
 	case DBTypeCass:
-		return cassandradb.NewClient(conf.CassandraConfig.ServerList, conf.CassandraConfig.KeySpace)
+		cassConf := conf.CassandraConfig
+		if conf.Logger != nil {
+			cassConf.Logger = conf.Logger
+		}
+		if conf.Metrics != nil {
+			cassConf.Metrics = conf.Metrics
+		}
+		if conf.TracerProvider != nil {
+			cassConf.TracerProvider = conf.TracerProvider
+		}
+		if conf.KeyProvider != nil {
+			cassConf.KeyProvider = conf.KeyProvider
+		}
+		cassConf.QueryHooks = append(append([]ops.QueryHook{}, conf.QueryHooks...), cassConf.QueryHooks...)
+		return cassandradb.NewClientWithConfig(cassConf)
 	default:
-		return nil, ops.ErrDBUnsupported
+		return nil, &ops.OpError{Op: ops.OpConnect, Kind: ops.ErrNoSupport, Err: ops.ErrDBUnsupported}
 	}
 }
@@ -0,0 +1,186 @@
+package cassandradb
+
+import (
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+var _ ops.Aggregator = (*Table)(nil)
+
+// numeric column types accepted by SUM and AVG
+var numericTypes = map[string]bool{
+	"int": true, "bigint": true, "smallint": true, "tinyint": true, "varint": true,
+	"float": true, "double": true, "decimal": true, "counter": true,
+}
+
+// Count returns the number of rows matching whereClause.
+func (t *Table) Count(whereClause []whc.WhereClauseType) (int64, error) {
+	rows, err := t.aggregate(ops.OpCount, []ops.Aggregation{{Func: ops.AggCount}}, whereClause, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	n, _ := rows[0].Int64("count")
+	return n, nil
+}
+
+// Aggregate computes spec over the rows matching whereClause, one row per
+// group of groupBy. groupBy must be a prefix of the primary key in key order
+// which starts with the partition key; key columns restricted by equality in
+// whereClause may be left out.
+func (t *Table) Aggregate(spec []ops.Aggregation, whereClause []whc.WhereClauseType,
+	groupBy []string) ([]ops.AggregateRow, error) {
+
+	return t.aggregate(ops.OpAggregate, spec, whereClause, groupBy)
+}
+
+func (t *Table) aggregate(op string, spec []ops.Aggregation, whereClause []whc.WhereClauseType,
+	groupBy []string) ([]ops.AggregateRow, error) {
+
+	if len(spec) == 0 {
+		return nil, t.invalidQuery(op, "no aggregate requested")
+	}
+	for _, wc := range whereClause {
+		if _, ok := t.entity(strings.ToLower(wc.ColumnName)); !ok {
+			return nil, t.invalidQuery(op, "invalid field in where clause :: %s", wc.ColumnName)
+		}
+	}
+	whereClause, err := t.encryptWhere(op, whereClause)
+	if err != nil {
+		return nil, err
+	}
+	groupBy, err = t.checkGroupBy(op, groupBy, whereClause)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append([]string{}, groupBy...)
+	names := make(map[string]bool)
+	for _, agg := range spec {
+		expr, err := t.aggregateExpr(op, agg)
+		if err != nil {
+			return nil, err
+		}
+		name := agg.Name()
+		if !isAggregateName(name) {
+			return nil, t.invalidQuery(op, "invalid aggregate name :: %s", name)
+		}
+		if names[name] {
+			return nil, t.invalidQuery(op, "duplicate aggregate name :: %s", name)
+		}
+		names[name] = true
+		columns = append(columns, expr+" AS "+name)
+	}
+
+	buffer, _ := getReadQueryString(t.entities, columns, t.KeySpace, t.Name, whereClause, groupBy, nil)
+
+	var result []ops.AggregateRow
+	err = t.conn.do(t.context(), t.queryInfo(op, buffer.String()), nil, func(q *gocql.Query) (int, error) {
+		iter := q.Iter()
+		row := make(map[string]interface{})
+		for iter.MapScan(row) {
+			agg := ops.AggregateRow{Group: make(map[string]interface{}), Values: make(map[string]interface{})}
+			for _, col := range groupBy {
+				agg.Group[col] = row[col]
+			}
+			for name := range names {
+				agg.Values[name] = row[name]
+			}
+			result = append(result, agg)
+			row = make(map[string]interface{})
+		}
+		return len(result), iter.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// aggregateExpr returns the select expression of agg.
+func (t *Table) aggregateExpr(op string, agg ops.Aggregation) (string, error) {
+	fn := ops.AggFunc(strings.ToUpper(string(agg.Func)))
+	switch fn {
+	case ops.AggCount:
+		if agg.Column == "" || agg.Column == "*" {
+			return "COUNT(*)", nil
+		}
+	case ops.AggSum, ops.AggAvg, ops.AggMin, ops.AggMax:
+	default:
+		return "", t.invalidQuery(op, "invalid aggregate function :: %s", agg.Func)
+	}
+	entity, ok := t.entity(strings.ToLower(agg.Column))
+	if !ok {
+		return "", t.invalidQuery(op, "invalid aggregate column :: %s", agg.Column)
+	}
+	if (fn == ops.AggSum || fn == ops.AggAvg) && !numericTypes[entity.columnType] {
+		return "", t.invalidQuery(op, "%s of non numeric column :: %s", fn, agg.Column)
+	}
+	if entity.columnType == "collection" {
+		return "", t.invalidQuery(op, "%s of collection column :: %s", fn, agg.Column)
+	}
+	if entity.encrypt != "" {
+		return "", t.invalidQuery(op, "%s of encrypted column :: %s", fn, agg.Column)
+	}
+	return string(fn) + "(" + entity.columnName + ")", nil
+}
+
+// checkGroupBy validates that groupBy is legal for the key layout of the
+// table and returns the column names it groups by.
+func (t *Table) checkGroupBy(op string, groupBy []string, whereClause []whc.WhereClauseType) ([]string, error) {
+	if len(groupBy) == 0 {
+		return nil, nil
+	}
+	restricted := make(map[string]bool)
+	for _, wc := range whereClause {
+		if strings.TrimSpace(wc.RelationType) == "=" {
+			restricted[strings.ToLower(wc.ColumnName)] = true
+		}
+	}
+
+	keys := append(keyColumns(t.entities, true), keyColumns(t.entities, false)...)
+	partitionKeys := len(keyColumns(t.entities, true))
+	cols := make([]string, len(groupBy))
+	next := 0
+	for i, g := range groupBy {
+		col := strings.ToLower(strings.TrimSpace(g))
+		// key columns fixed by the where clause may be skipped
+		for next < len(keys) && keys[next] != col && restricted[keys[next]] {
+			next++
+		}
+		if next >= len(keys) || keys[next] != col {
+			if _, ok := t.entity(col); !ok {
+				return nil, t.invalidQuery(op, "invalid field in group by :: %s", g)
+			}
+			return nil, t.invalidQuery(op, "group by must follow the primary key order, got :: %s", g)
+		}
+		cols[i] = col
+		next++
+	}
+	// grouping within a partition needs the whole partition key
+	for i := 0; i < partitionKeys; i++ {
+		if i >= next && !restricted[keys[i]] {
+			return nil, t.invalidQuery(op, "group by must include the partition key column :: %s", keys[i])
+		}
+	}
+	return cols, nil
+}
+
+// isAggregateName reports whether name can be used unquoted as the alias
+// of an aggregate, a lower case cql identifier.
+func isAggregateName(name string) bool {
+	if name == "" || isDigit(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if ch := name[i]; !isIdentChar(ch) || (ch >= 'A' && ch <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package cassandradb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pageView struct {
+	Tenant string  `cql:"column_name=tenant,primary_key=0"`
+	Region string  `cql:"column_name=region,primary_key=1"`
+	Day    string  `cql:"column_name=day,clustering_key=0"`
+	Hour   int     `cql:"column_name=hour,clustering_key=1"`
+	Views  int     `cql:"column_name=views"`
+	Score  float64 `cql:"column_name=score,column_type=double"`
+	Page   string  `cql:"column_name=page"`
+}
+
+// newRecordingTable returns a table without a session whose statements are
+// recorded by a query hook.
+func newRecordingTable(t *testing.T, model interface{}) (*Table, *[]string) {
+	entities, err := CreateEntity(model)
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var stmts []string
+	cn := newConn(nil)
+	cn.addHooks(ops.QueryHookFuncs{BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stmts = append(stmts, info.Statement)
+		return ctx, nil
+	}})
+	return &Table{Name: "views", KeySpace: "ks", entities: entities, dataModel: model, conn: cn,
+		stmts: newStmtCache(stmtCacheSize)}, &stmts
+}
+
+func TestAggregateStatement(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"}}
+	spec := []ops.Aggregation{{Func: ops.AggCount}, {Func: ops.AggSum, Column: "views"}, {Func: ops.AggMax, Column: "score", Alias: "best"}}
+
+	_, err := tbl.Aggregate(spec, where, []string{"region", "day"})
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	_, err = tbl.Count(where)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+
+	require.Len(t, *stmts, 2)
+	assert.Equal(t, "SELECT region, day, COUNT(*) AS count, SUM(views) AS sum_views, MAX(score) AS best "+
+		"FROM ks.views WHERE tenant = 'acme' GROUP BY region , day;", (*stmts)[0])
+	assert.Equal(t, "SELECT COUNT(*) AS count FROM ks.views WHERE tenant = 'acme';", (*stmts)[1])
+}
+
+func TestAggregateGroupByValidation(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	count := []ops.Aggregation{{Func: ops.AggCount}}
+	tenant := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"}}
+
+	legal := []struct {
+		where   []whc.WhereClauseType
+		groupBy []string
+	}{
+		{nil, []string{"tenant", "region"}},
+		{nil, []string{"tenant", "region", "day", "hour"}},
+		{tenant, []string{"region", "day"}},
+	}
+	for _, c := range legal {
+		_, err := tbl.Aggregate(count, c.where, c.groupBy)
+		assert.NotErrorIs(t, err, ops.ErrInvalidQuery, c.groupBy)
+	}
+
+	illegal := []struct {
+		where   []whc.WhereClauseType
+		groupBy []string
+	}{
+		{nil, []string{"tenant"}},
+		{nil, []string{"region", "tenant"}},
+		{nil, []string{"tenant", "region", "hour"}},
+		{nil, []string{"tenant", "region", "page"}},
+		{nil, []string{"nope"}},
+		{tenant, []string{"day"}},
+	}
+	for _, c := range illegal {
+		_, err := tbl.Aggregate(count, c.where, c.groupBy)
+		assert.ErrorIs(t, err, ops.ErrInvalidQuery, c.groupBy)
+	}
+	assert.Len(t, *stmts, len(legal))
+}
+
+func TestAggregateSpecValidation(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	bad := [][]ops.Aggregation{
+		nil,
+		{{Func: ops.AggSum, Column: "page"}},
+		{{Func: ops.AggAvg, Column: "nope"}},
+		{{Func: "MEDIAN", Column: "views"}},
+		{{Func: ops.AggMin, Column: "views"}, {Func: ops.AggMin, Column: "views"}},
+		{{Func: ops.AggCount, Alias: "n FROM ks.other; --"}},
+		{{Func: ops.AggCount, Alias: "total count"}},
+		{{Func: ops.AggCount, Alias: "1st"}},
+		{{Func: ops.AggCount, Alias: "\"n\""}},
+	}
+	for _, spec := range bad {
+		_, err := tbl.Aggregate(spec, nil, nil)
+		assert.ErrorIs(t, err, ops.ErrInvalidQuery, spec)
+	}
+	assert.Empty(t, *stmts)
+
+	_, err := tbl.Aggregate([]ops.Aggregation{{Func: ops.AggCount, Alias: "Total_2"}}, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Equal(t, []string{"SELECT COUNT(*) AS total_2 FROM ks.views ;"}, *stmts)
+}
+
+func TestAggregateRowConversions(t *testing.T) {
+	row := ops.AggregateRow{Values: map[string]interface{}{"count": int64(3), "sum_views": 12, "avg_score": 1.5}}
+	n, ok := row.Int64("count")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), n)
+	f, ok := row.Float64("sum_views")
+	assert.True(t, ok)
+	assert.Equal(t, 12.0, f)
+	_, ok = row.Int64("avg_score")
+	assert.False(t, ok)
+}
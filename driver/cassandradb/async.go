@@ -0,0 +1,143 @@
+package cassandradb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+// Future is the pending result of an asynchronous operation.
+type Future struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// goFuture runs fn in a goroutine and returns its future.
+func goFuture(fn func() (interface{}, error)) *Future {
+	f := &Future{done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		f.val, f.err = fn()
+	}()
+	return f
+}
+
+// Done is closed when the operation has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait returns the result of the operation once it has finished, or the
+// error of ctx when ctx is done first. The operation itself is bounded by
+// the context of the table it was started on, not by ctx.
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InsertAsync inserts data in the background.
+func (t *Table) InsertAsync(data interface{}) *Future {
+	return goFuture(func() (interface{}, error) {
+		return nil, t.Insert(data)
+	})
+}
+
+// UpdateAsync updates the row of data in the background.
+func (t *Table) UpdateAsync(data interface{}) *Future {
+	return goFuture(func() (interface{}, error) {
+		return nil, t.Update(data)
+	})
+}
+
+// DeleteAsync deletes rows in the background.
+func (t *Table) DeleteAsync(deleteColumnList []string, whereClause []whc.WhereClauseType) *Future {
+	return goFuture(func() (interface{}, error) {
+		return nil, t.Delete(deleteColumnList, whereClause)
+	})
+}
+
+// ReadAsync reads one row in the background, the future returns the row
+// like Read.
+func (t *Table) ReadAsync(whereClause []whc.WhereClauseType, groupByClause []string,
+	orderByClause map[string]string) *Future {
+
+	return goFuture(func() (interface{}, error) {
+		return t.Read(whereClause, groupByClause, orderByClause)
+	})
+}
+
+// Key holds the values of the primary key of a row, partition key columns
+// first, then the clustering columns, in key order. Trailing clustering
+// columns may be left out.
+type Key []interface{}
+
+// ReadResult is the outcome of the read of one key by ReadMany.
+type ReadResult struct {
+	Key Key
+	// Row is the row read, a value of the data model of the table
+	Row interface{}
+	Err error
+}
+
+// ReadMany reads the row of every key, running at most parallelism reads
+// at a time. The results are in the order of keys and carry the error of
+// their own read, ops.ErrNotFound for a missing row. Keys not read yet when
+// the context of the table is done fail with its error.
+func (t *Table) ReadMany(keys []Key, parallelism int) []ReadResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > len(keys) {
+		parallelism = len(keys)
+	}
+	results := make([]ReadResult, len(keys))
+	columns := append(keyColumns(t.entities, true), keyColumns(t.entities, false)...)
+	ctx := t.context()
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = t.readKey(ctx, columns, keys[i])
+			}
+		}()
+	}
+	for i := range keys {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// readKey reads the row of key, columns are the key columns of the table.
+func (t *Table) readKey(ctx context.Context, columns []string, key Key) ReadResult {
+	result := ReadResult{Key: key}
+	if err := ctx.Err(); err != nil {
+		result.Err = wrapError(t.queryInfo(ops.OpRead, ""), err)
+		return result
+	}
+	if len(key) == 0 || len(key) > len(columns) {
+		result.Err = t.invalidQuery(ops.OpRead, "key has %d values, table has %d key columns", len(key), len(columns))
+		return result
+	}
+	where := make([]whc.WhereClauseType, len(key))
+	for i, v := range key {
+		where[i] = whc.WhereClauseType{ColumnName: columns[i], RelationType: "=", ColumnValue: v}
+	}
+	result.Row, result.Err = t.Read(where, nil, nil)
+	return result
+}
@@ -0,0 +1,100 @@
+package cassandradb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFutureWait(t *testing.T) {
+	release := make(chan struct{})
+	f := goFuture(func() (interface{}, error) {
+		<-release
+		return "row", nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := f.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	val, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "row", val)
+	<-f.Done()
+}
+
+func TestAsyncOperations(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"}}
+
+	insert := tbl.InsertAsync(&pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"})
+	read := tbl.ReadAsync(where, nil, nil)
+
+	_, err := insert.Wait(context.Background())
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	row, err := read.Wait(context.Background())
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Nil(t, row)
+	assert.Len(t, *stmts, 2)
+}
+
+func TestReadManyOrderAndErrors(t *testing.T) {
+	tbl, _ := newRecordingTable(t, pageView{})
+	var mu sync.Mutex
+	var running, most int
+	tbl.conn.addHooks(ops.QueryHookFuncs{
+		BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			running++
+			if running > most {
+				most = running
+			}
+			return ctx, nil
+		},
+		AfterFunc: func(ctx context.Context, info ops.QueryInfo, result ops.QueryResult, err error) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+		},
+	})
+
+	keys := []Key{{"acme", "eu"}, {}, {"acme", "us", "2024-05-01", 10, "extra"}}
+	for i := 0; i < 20; i++ {
+		keys = append(keys, Key{"acme", "eu", "2024-05-01", i})
+	}
+	results := tbl.ReadMany(keys, 4)
+
+	require.Len(t, results, len(keys))
+	for i, result := range results {
+		assert.Equal(t, keys[i], result.Key)
+		assert.Nil(t, result.Row)
+	}
+	assert.ErrorIs(t, results[0].Err, ops.ErrUnavailable)
+	assert.ErrorIs(t, results[1].Err, ops.ErrInvalidQuery)
+	assert.ErrorIs(t, results[2].Err, ops.ErrInvalidQuery)
+	assert.ErrorIs(t, results[22].Err, ops.ErrUnavailable)
+	assert.LessOrEqual(t, most, 4)
+	assert.Equal(t, StatementCacheStats{Hits: 19, Misses: 2, Size: 2}, tbl.StatementCacheStats())
+}
+
+func TestReadManyCanceled(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := tbl.WithContext(ctx).ReadMany([]Key{{"acme"}, {"globex"}}, 2)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, context.Canceled)
+	}
+	assert.Empty(t, *stmts)
+}
@@ -0,0 +1,124 @@
+package cassandradb
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// Values of the auto option of the cql tag, columns the table fills in:
+//
+//	auto=uuid      a random uuid, when the field is zero on insert
+//	auto=timeuuid  a time based uuid, when the field is zero on insert
+//	auto=created   the time of the insert, when the field is zero; Update
+//	               never writes the column
+//	auto=updated   the time of every update, and of the insert when the
+//	               field is zero so that copied rows keep their times
+//
+// uuid fields are a gocql.UUID, a [16]byte or a string, timestamp fields a
+// time.Time. The values are written back into the row when it is passed
+// by pointer.
+const (
+	autoUUID     = "uuid"
+	autoTimeUUID = "timeuuid"
+	autoCreated  = "created"
+	autoUpdated  = "updated"
+)
+
+var uuidType = reflect.TypeOf(gocql.UUID{})
+
+// parseAuto checks the auto option of field.
+func parseAuto(field reflect.StructField, auto string) error {
+	var ok bool
+	switch auto {
+	case autoUUID, autoTimeUUID:
+		ok = field.Type.Kind() == reflect.String || field.Type.ConvertibleTo(uuidType)
+	case autoCreated, autoUpdated:
+		ok = field.Type == timeType
+	default:
+		return CassandraDBError{time.Now(), "invalid auto option on " + field.Name, auto}
+	}
+	if !ok {
+		return CassandraDBError{time.Now(), "invalid type for auto=" + auto, field.Name + " " + field.Type.String()}
+	}
+	return nil
+}
+
+// autoNow returns the time auto timestamps are set to, at the millisecond
+// precision of cassandra so that the row read back is the row written.
+func autoNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// autoValue returns the value to write for the field of an auto column,
+// insert tells an insert from an update. The field is set to a generated
+// value when it can be.
+func autoValue(entity Entity, field reflect.Value, now time.Time, insert bool) (reflect.Value, error) {
+	var v reflect.Value
+	switch entity.auto {
+	case autoUUID, autoTimeUUID:
+		if !insert || !field.IsZero() {
+			return field, nil
+		}
+		id := gocql.TimeUUID()
+		if entity.auto == autoUUID {
+			var err error
+			if id, err = gocql.RandomUUID(); err != nil {
+				return field, err
+			}
+		}
+		if field.Kind() == reflect.String {
+			v = reflect.ValueOf(id.String()).Convert(field.Type())
+		} else {
+			v = reflect.ValueOf(id).Convert(field.Type())
+		}
+	case autoCreated:
+		if !insert || !field.IsZero() {
+			return field, nil
+		}
+		v = reflect.ValueOf(now)
+	case autoUpdated:
+		if insert && !field.IsZero() {
+			return field, nil
+		}
+		v = reflect.ValueOf(now)
+	default:
+		return field, nil
+	}
+	if field.CanSet() {
+		field.Set(v)
+	}
+	return v, nil
+}
+
+// autoUpdate returns updateMap with the auto=updated columns it does not
+// set set to now. Changing an auto=created column is rejected.
+func (t *Table) autoUpdate(updateMap map[string]interface{}) (map[string]interface{}, error) {
+	if len(updateMap) == 0 {
+		return updateMap, nil
+	}
+	var stamped map[string]interface{}
+	now := autoNow()
+	for _, entity := range t.entities {
+		_, ok := updateMap[entity.columnName]
+		switch {
+		case entity.auto == autoCreated && ok:
+			return nil, t.invalidQuery(ops.OpUpdate, "cannot update created column :: %s", entity.columnName)
+		case entity.auto == autoUpdated && !ok:
+			if stamped == nil {
+				// the map of the caller is left as it is
+				stamped = make(map[string]interface{}, len(updateMap)+1)
+				for k, v := range updateMap {
+					stamped[k] = v
+				}
+			}
+			stamped[entity.columnName] = now
+		}
+	}
+	if stamped == nil {
+		return updateMap, nil
+	}
+	return stamped, nil
+}
@@ -0,0 +1,121 @@
+package cassandradb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type autoDoc struct {
+	ID      gocql.UUID `cql:"column_name=id,primary_key=0,auto=timeuuid"`
+	Ref     string     `cql:"column_name=ref,auto=uuid"`
+	Title   string     `cql:"column_name=title"`
+	Created time.Time  `cql:"column_name=created,column_type=timestamp,auto=created"`
+	Updated time.Time  `cql:"column_name=updated,column_type=timestamp,auto=updated"`
+}
+
+func TestAutoFieldsOnInsert(t *testing.T) {
+	tbl, _ := newRecordingTable(t, autoDoc{})
+
+	doc := autoDoc{Title: "a"}
+	assert.ErrorIs(t, tbl.Insert(&doc), ops.ErrUnavailable)
+	assert.Equal(t, 1, doc.ID.Version())
+	ref, err := gocql.ParseUUID(doc.Ref)
+	require.NoError(t, err)
+	assert.Equal(t, 4, ref.Version())
+	assert.False(t, doc.Created.IsZero())
+	assert.Equal(t, doc.Created, doc.Updated)
+	assert.Equal(t, doc.Created, doc.Created.Truncate(time.Millisecond))
+
+	// values already set are kept
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	again := autoDoc{ID: doc.ID, Ref: doc.Ref, Created: created, Updated: updated}
+	_, values, err := tbl.insertStatement(&again, false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{doc.ID, doc.Ref, "", created, updated}, values)
+	assert.Equal(t, updated, again.Updated)
+
+	// a row passed by value gets generated values, the caller's copy does not
+	byValue := autoDoc{Title: "b"}
+	_, values, err = tbl.insertStatement(byValue, false)
+	require.NoError(t, err)
+	assert.NotEqual(t, gocql.UUID{}, values[0])
+	assert.Equal(t, autoDoc{Title: "b"}, byValue)
+}
+
+func TestAutoFieldsOnUpdate(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, autoDoc{})
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	doc := autoDoc{ID: gocql.TimeUUID(), Ref: "r", Title: "a", Created: created, Updated: created}
+
+	assert.ErrorIs(t, tbl.Update(&doc), ops.ErrUnavailable)
+	assert.Equal(t, created, doc.Created)
+	assert.True(t, doc.Updated.After(created))
+
+	where := []whc.WhereClauseType{{ColumnName: "id", RelationType: "=", ColumnValue: doc.ID}}
+	fields := map[string]interface{}{"title": "b"}
+	assert.ErrorIs(t, tbl.UpdateFields(fields, nil, where), ops.ErrUnavailable)
+	assert.Equal(t, map[string]interface{}{"title": "b"}, fields)
+
+	require.Len(t, *stmts, 2)
+	assert.Equal(t, "UPDATE ks.views SET ref = ? , title = ? , updated = ? WHERE id = ?;", (*stmts)[0])
+	assert.Equal(t, "UPDATE ks.views SET title = ? , updated = ? WHERE id = ?;", (*stmts)[1])
+
+	err := tbl.UpdateFields(map[string]interface{}{"created": time.Now()}, nil, where)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+	assert.ErrorIs(t, tbl.UpdateFields(nil, nil, where), ops.ErrInvalidQuery)
+	assert.Len(t, *stmts, 2)
+}
+
+func TestAutoTags(t *testing.T) {
+	for _, model := range []interface{}{
+		struct {
+			ID string `cql:"column_name=id,primary_key=0,auto=serial"`
+		}{},
+		struct {
+			ID int `cql:"column_name=id,primary_key=0,auto=uuid"`
+		}{},
+		struct {
+			ID string `cql:"column_name=id,primary_key=0"`
+			At string `cql:"column_name=at,auto=created"`
+		}{},
+	} {
+		_, err := CreateEntity(model)
+		assert.Error(t, err, "%T", model)
+	}
+}
+
+func TestAutoFieldsKeptOnImport(t *testing.T) {
+	tbl, _ := newRecordingTable(t, autoDoc{})
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	doc := autoDoc{ID: gocql.TimeUUID(), Ref: "r", Title: "a", Created: created, Updated: updated}
+
+	columns, err := exportColumns(tbl, ops.OpScan)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	enc, err := newRowEncoder(tbl, &out, FormatJSONL, columns)
+	require.NoError(t, err)
+	require.NoError(t, enc.encode(reflect.ValueOf(doc)))
+	require.NoError(t, enc.flush())
+
+	var rows []interface{}
+	_, err = Import(tbl, &out, FormatJSONL, ImportOptions{BatchSize: 1,
+		DeadLetter: func(row interface{}, err error) { rows = append(rows, row) }})
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	require.Len(t, rows, 1)
+	assert.Equal(t, doc, rows[0])
+
+	// the rows the writer inserts keep their times
+	_, values, err := tbl.insertStatement(rows[0], false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{doc.ID, "r", "a", created, updated}, values)
+}
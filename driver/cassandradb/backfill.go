@@ -0,0 +1,310 @@
+package cassandradb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// Checkpoint stores the token ranges a backfill job has completed.
+// Implementations must be safe for concurrent use.
+type Checkpoint interface {
+	// Completed returns the ranges recorded for job.
+	Completed(job string) ([]TokenRange, error)
+	// MarkDone records that r of job has been processed.
+	MarkDone(job string, r TokenRange, rows int64) error
+}
+
+// BackfillFunc is called with every row of a backfill, decoded into the
+// data model. ctx is cancelled when the backfill stops early, on the error
+// of another row or range. In a dry run IsDryRun(ctx) reports true and the
+// function should not write anything.
+type BackfillFunc func(ctx context.Context, row interface{}) error
+
+// BackfillOptions controls a backfill.
+type BackfillOptions struct {
+	// Job names the backfill in the checkpoint, a job resumes where the
+	// last run with the same name stopped.
+	Job string
+	// Checkpoint records the completed ranges, the backfill cannot be
+	// resumed when it is nil.
+	Checkpoint Checkpoint
+	// Scan sets the parallelism, retries and page size of the scan. Its
+	// Ranges, if set, restrict the backfill to those ranges.
+	Scan ScanOptions
+	// RowsPerSecond limits the rate fn is called at, unlimited when zero.
+	RowsPerSecond float64
+	// DryRun runs fn without recording checkpoints.
+	DryRun bool
+}
+
+// BackfillReport summarizes a backfill run.
+type BackfillReport struct {
+	Job string
+	// Ranges is the number of ranges left to process when the run started,
+	// RangesSkipped the ranges completed by earlier runs.
+	Ranges        int
+	RangesSkipped int
+	RangesDone    int
+	Rows          int64
+	Duration      time.Duration
+	DryRun        bool
+}
+
+type dryRunKey struct{}
+
+// IsDryRun reports whether ctx belongs to a dry run backfill.
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// Backfill runs fn over every row of the table. The token ranges it has
+// completed are recorded in opts.Checkpoint, so that a run that was
+// interrupted can be started again without processing them twice. Rows of
+// a range that was interrupted are processed again.
+func (t *Table) Backfill(ctx context.Context, opts BackfillOptions, fn BackfillFunc) (BackfillReport, error) {
+	start := time.Now()
+	report := BackfillReport{Job: opts.Job, DryRun: opts.DryRun}
+	if opts.Job == "" {
+		return report, t.invalidQuery(ops.OpBackfill, "backfill needs a job name")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tbl := t.WithContext(ctx)
+
+	scanOpts := opts.Scan
+	if scanOpts.Parallelism < 1 {
+		scanOpts.Parallelism = 1
+	}
+	if scanOpts.SplitsPerWorker < 1 {
+		scanOpts.SplitsPerWorker = 4
+	}
+	ranges := scanOpts.Ranges
+	if ranges == nil {
+		tokens, err := tbl.ringTokens()
+		if err != nil {
+			return report, err
+		}
+		ranges = splitRing(tokens, scanOpts.Parallelism*scanOpts.SplitsPerWorker)
+	}
+	if opts.Checkpoint != nil {
+		done, err := opts.Checkpoint.Completed(opts.Job)
+		if err != nil {
+			return report, err
+		}
+		var remaining []TokenRange
+		for _, r := range ranges {
+			left := subtractRanges([]TokenRange{r}, done)
+			if len(left) == 0 {
+				report.RangesSkipped++
+			}
+			remaining = append(remaining, left...)
+		}
+		ranges = remaining
+	}
+	report.Ranges = len(ranges)
+	if len(ranges) == 0 {
+		report.Duration = time.Since(start)
+		return report, nil
+	}
+	scanOpts.Ranges = ranges
+
+	var limiter *tokenBucket
+	if opts.RowsPerSecond > 0 {
+		limiter = newTokenBucket(opts.RowsPerSecond, scanOpts.Parallelism)
+	}
+
+	var mu sync.Mutex
+	var checkpointErr error
+	rangeDone := scanOpts.RangeDone
+	scanOpts.RangeDone = func(r TokenRange, rows int64) {
+		mu.Lock()
+		report.RangesDone++
+		report.Rows += rows
+		mu.Unlock()
+		if opts.Checkpoint != nil && !opts.DryRun {
+			if err := opts.Checkpoint.MarkDone(opts.Job, r, rows); err != nil {
+				mu.Lock()
+				if checkpointErr == nil {
+					checkpointErr = err
+				}
+				mu.Unlock()
+			}
+		}
+		if rangeDone != nil {
+			rangeDone(r, rows)
+		}
+	}
+
+	// fn and the wait for the limiter get the context of the scan, which is
+	// cancelled when another range stops the scan
+	err := tbl.scan(scanOpts, func(scanCtx context.Context, row interface{}) error {
+		if limiter != nil {
+			if err := limiter.wait(scanCtx); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			scanCtx = context.WithValue(scanCtx, dryRunKey{}, true)
+		}
+		return fn(scanCtx, row)
+	})
+	report.Duration = time.Since(start)
+	if err == nil {
+		err = checkpointErr
+	}
+	return report, err
+}
+
+// subtractRanges returns the parts of ranges not covered by done.
+func subtractRanges(ranges, done []TokenRange) []TokenRange {
+	done = append([]TokenRange{}, done...)
+	sort.Slice(done, func(i, j int) bool { return done[i].Start < done[j].Start })
+
+	var out []TokenRange
+	for _, r := range ranges {
+		cur := r.Start
+		for _, d := range done {
+			if d.End <= cur || d.Start >= r.End {
+				continue
+			}
+			if d.Start > cur {
+				out = append(out, TokenRange{Start: cur, End: d.Start})
+			}
+			if d.End > cur {
+				cur = d.End
+			}
+			if cur >= r.End {
+				break
+			}
+		}
+		if cur < r.End {
+			out = append(out, TokenRange{Start: cur, End: r.End})
+		}
+	}
+	return out
+}
+
+// fileCheckpoint appends completed ranges to a file, one JSON object per line.
+type fileCheckpoint struct {
+	sync.Mutex
+	path string
+}
+
+type checkpointEntry struct {
+	Job   string    `json:"job"`
+	Start int64     `json:"start"`
+	End   int64     `json:"end"`
+	Rows  int64     `json:"rows"`
+	At    time.Time `json:"at"`
+}
+
+// NewFileCheckpoint returns a Checkpoint that records completed ranges in
+// the file at path, which is created when missing.
+func NewFileCheckpoint(path string) Checkpoint {
+	return &fileCheckpoint{path: path}
+}
+
+func (c *fileCheckpoint) Completed(job string) ([]TokenRange, error) {
+	c.Lock()
+	defer c.Unlock()
+	f, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges []TokenRange
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last line is cut short when the process died writing it
+			continue
+		}
+		if entry.Job == job {
+			ranges = append(ranges, TokenRange{Start: entry.Start, End: entry.End})
+		}
+	}
+	return ranges, scanner.Err()
+}
+
+func (c *fileCheckpoint) MarkDone(job string, r TokenRange, rows int64) error {
+	line, err := json.Marshal(checkpointEntry{Job: job, Start: r.Start, End: r.End, Rows: rows, At: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// start on a fresh line in case an earlier write was cut short
+	if _, err := f.Write(append(append([]byte{'\n'}, line...), '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tableCheckpoint records completed ranges in a table.
+type tableCheckpoint struct {
+	conn     *conn
+	keyspace string
+	table    string
+}
+
+// NewTableCheckpoint returns a Checkpoint that records completed ranges in
+// table of keyspace k, which is created when missing.
+func NewTableCheckpoint(k *KeySpace, table string) (Checkpoint, error) {
+	c := &tableCheckpoint{conn: k.conn, keyspace: k.Name, table: table}
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (job text, range_start bigint, range_end bigint, "+
+		"rows bigint, done_at timestamp, PRIMARY KEY (job, range_start, range_end))", k.Name, table)
+	if err := c.conn.exec(context.Background(), c.queryInfo(ops.OpCreateTable, stmt)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *tableCheckpoint) queryInfo(op string, stmt string) ops.QueryInfo {
+	return ops.QueryInfo{Operation: op, Keyspace: c.keyspace, Table: c.table, Statement: stmt}
+}
+
+func (c *tableCheckpoint) Completed(job string) ([]TokenRange, error) {
+	stmt := fmt.Sprintf("SELECT range_start, range_end FROM %s.%s WHERE job = ?", c.keyspace, c.table)
+	var ranges []TokenRange
+	err := c.conn.iter(context.Background(), c.queryInfo(ops.OpBackfill, stmt), []interface{}{job},
+		func(iter *gocql.Iter) bool {
+			var r TokenRange
+			if !iter.Scan(&r.Start, &r.End) {
+				return false
+			}
+			ranges = append(ranges, r)
+			return true
+		})
+	return ranges, err
+}
+
+func (c *tableCheckpoint) MarkDone(job string, r TokenRange, rows int64) error {
+	stmt := fmt.Sprintf("INSERT INTO %s.%s (job, range_start, range_end, rows, done_at) VALUES (?, ?, ?, ?, ?)",
+		c.keyspace, c.table)
+	return c.conn.exec(context.Background(), c.queryInfo(ops.OpInsert, stmt), job, r.Start, r.End, rows, time.Now())
+}
@@ -0,0 +1,115 @@
+package cassandradb
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubtractRanges(t *testing.T) {
+	ranges := []TokenRange{{Start: 0, End: 100}, {Start: 100, End: 200}}
+	done := []TokenRange{{Start: 150, End: 200}, {Start: 0, End: 10}, {Start: 40, End: 60}}
+	assert.Equal(t, []TokenRange{{Start: 10, End: 40}, {Start: 60, End: 100}, {Start: 100, End: 150}},
+		subtractRanges(ranges, done))
+	assert.Empty(t, subtractRanges(ranges, []TokenRange{{Start: math.MinInt64, End: math.MaxInt64}}))
+	assert.Equal(t, ranges, subtractRanges(ranges, nil))
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.jsonl")
+	cp := NewFileCheckpoint(path)
+	done, err := cp.Completed("job")
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	require.NoError(t, cp.MarkDone("job", TokenRange{Start: 1, End: 2}, 10))
+	require.NoError(t, cp.MarkDone("other", TokenRange{Start: 2, End: 3}, 10))
+	// a write cut short by a crash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	f.WriteString(`{"job":"job","sta`)
+	f.Close()
+	require.NoError(t, cp.MarkDone("job", TokenRange{Start: 5, End: 6}, 10))
+
+	done, err = cp.Completed("job")
+	require.NoError(t, err)
+	assert.Equal(t, []TokenRange{{Start: 1, End: 2}, {Start: 5, End: 6}}, done)
+}
+
+func TestBackfillResumes(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	cp := NewFileCheckpoint(filepath.Join(t.TempDir(), "backfill.jsonl"))
+	ranges := []TokenRange{{Start: math.MinInt64, End: 0}, {Start: 0, End: math.MaxInt64}}
+	require.NoError(t, cp.MarkDone("views", ranges[0], 3))
+
+	opts := BackfillOptions{Job: "views", Checkpoint: cp, Scan: ScanOptions{Ranges: ranges}}
+	report, err := tbl.Backfill(context.Background(), opts, func(ctx context.Context, row interface{}) error {
+		return nil
+	})
+	// only the range left over is read, and it fails without a session
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Len(t, *stmts, 1)
+	assert.Equal(t, 1, report.RangesSkipped)
+	assert.Equal(t, 1, report.Ranges)
+
+	require.NoError(t, cp.MarkDone("views", ranges[1], 3))
+	report, err = tbl.Backfill(context.Background(), opts, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Ranges)
+	assert.Len(t, *stmts, 1)
+
+	_, err = tbl.Backfill(context.Background(), BackfillOptions{}, nil)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestTableCheckpointBudgets(t *testing.T) {
+	cn := newConn(nil)
+	var classes []string
+	cn.addHooks(ops.QueryHookFuncs{BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+		classes = append(classes, ops.OperationClass(info.Operation))
+		return ctx, nil
+	}})
+	cp := &tableCheckpoint{conn: cn, keyspace: "ks", table: "backfills"}
+
+	_, err := cp.Completed("job")
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.ErrorIs(t, cp.MarkDone("job", TokenRange{Start: 1, End: 2}, 10), ops.ErrUnavailable)
+	assert.Equal(t, []string{ops.ClassRead, ops.ClassWrite}, classes)
+
+	// a spent read budget does not hold up the checkpoint writes
+	cn.setLimits(LimitsConfig{Reads: LimitConfig{Rate: 0.001, Burst: 1}})
+	_, err = cp.Completed("job")
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	_, err = cp.Completed("job")
+	assert.ErrorIs(t, err, ops.ErrRateLimited)
+	assert.ErrorIs(t, cp.MarkDone("job", TokenRange{Start: 1, End: 2}, 10), ops.ErrUnavailable)
+}
+
+func TestIsDryRun(t *testing.T) {
+	assert.False(t, IsDryRun(context.Background()))
+	assert.True(t, IsDryRun(context.WithValue(context.Background(), dryRunKey{}, true)))
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, b.allow())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.wait(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,305 @@
+package cassandradb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/meooio/goava/ops"
+)
+
+// BreakerSettings configures the circuit breaker of an operation class.
+type BreakerSettings struct {
+	Enabled bool `toml:"enabled"`
+	// Window is the period the error and timeout rates are measured over.
+	Window time.Duration `toml:"window"`
+	// MinRequests is the number of statements in the window below which
+	// the breaker does not open.
+	MinRequests int `toml:"min_requests"`
+	// ErrorRate opens the breaker when the fraction of statements failing
+	// because the cluster is unavailable, overloaded or timing out reaches
+	// it. Zero disables the check.
+	ErrorRate float64 `toml:"error_rate"`
+	// TimeoutRate opens the breaker when the fraction of statements timing
+	// out reaches it. Zero disables the check.
+	TimeoutRate float64 `toml:"timeout_rate"`
+	// OpenFor is how long the breaker stays open before it lets probes through.
+	OpenFor time.Duration `toml:"open_for"`
+	// HalfOpenProbes is the number of statements let through while half
+	// open, the breaker closes once they all succeed.
+	HalfOpenProbes int `toml:"half_open_probes"`
+}
+
+// BreakerConfig holds the circuit breaker settings per operation class.
+type BreakerConfig struct {
+	Reads  BreakerSettings `toml:"reads"`
+	Writes BreakerSettings `toml:"writes"`
+	DDL    BreakerSettings `toml:"ddl"`
+}
+
+// DefaultBreakerSettings are used for the settings left zero in an enabled
+// BreakerSettings.
+var DefaultBreakerSettings = BreakerSettings{
+	Window:         10 * time.Second,
+	MinRequests:    20,
+	ErrorRate:      0.5,
+	OpenFor:        5 * time.Second,
+	HalfOpenProbes: 1,
+}
+
+func (s BreakerSettings) withDefaults() BreakerSettings {
+	if s.Window <= 0 {
+		s.Window = DefaultBreakerSettings.Window
+	}
+	if s.MinRequests <= 0 {
+		s.MinRequests = DefaultBreakerSettings.MinRequests
+	}
+	if s.ErrorRate <= 0 && s.TimeoutRate <= 0 {
+		s.ErrorRate = DefaultBreakerSettings.ErrorRate
+	}
+	if s.OpenFor <= 0 {
+		s.OpenFor = DefaultBreakerSettings.OpenFor
+	}
+	if s.HalfOpenProbes <= 0 {
+		s.HalfOpenProbes = DefaultBreakerSettings.HalfOpenProbes
+	}
+	return s
+}
+
+const breakerBuckets = 10
+
+// breakerBucket counts the outcomes of the statements of a slice of the window.
+type breakerBucket struct {
+	start    time.Time
+	total    int
+	failures int
+	timeouts int
+}
+
+// breaker is the circuit breaker of an operation class.
+type breaker struct {
+	sync.Mutex
+	class    string
+	settings BreakerSettings
+	state    ops.BreakerState
+	openedAt time.Time
+	// probes in flight and succeeded while half open
+	probes    int
+	succeeded int
+	buckets   [breakerBuckets]breakerBucket
+	now       func() time.Time
+	notify    func(ops.BreakerChange)
+}
+
+func newBreaker(class string, settings BreakerSettings, notify func(ops.BreakerChange)) *breaker {
+	return &breaker{class: class, settings: settings.withDefaults(), state: ops.BreakerClosed,
+		now: time.Now, notify: notify}
+}
+
+// allow reports whether a statement may run. A statement that was allowed
+// must report its outcome with record.
+func (b *breaker) allow() bool {
+	b.Lock()
+	var change *ops.BreakerChange
+	defer func() {
+		b.Unlock()
+		if change != nil {
+			b.notify(*change)
+		}
+	}()
+	now := b.now()
+	switch b.state {
+	case ops.BreakerOpen:
+		if now.Sub(b.openedAt) < b.settings.OpenFor {
+			return false
+		}
+		change = b.transition(ops.BreakerHalfOpen, "open period elapsed", now)
+		fallthrough
+	case ops.BreakerHalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// record reports the outcome of a statement allowed by allow.
+func (b *breaker) record(err error) {
+	failure := isClusterFailure(err)
+	timeout := errors.Is(err, ops.ErrTimeout)
+
+	b.Lock()
+	var change *ops.BreakerChange
+	defer func() {
+		b.Unlock()
+		if change != nil {
+			b.notify(*change)
+		}
+	}()
+	now := b.now()
+	switch b.state {
+	case ops.BreakerHalfOpen:
+		if failure {
+			change = b.open("probe failed", now)
+			return
+		}
+		b.succeeded++
+		if b.succeeded >= b.settings.HalfOpenProbes {
+			change = b.transition(ops.BreakerClosed, "probes succeeded", now)
+		}
+	case ops.BreakerClosed:
+		bucket := b.bucket(now)
+		bucket.total++
+		if failure {
+			bucket.failures++
+		}
+		if timeout {
+			bucket.timeouts++
+		}
+		if reason := b.tripped(now); reason != "" {
+			change = b.open(reason, now)
+		}
+	}
+}
+
+// bucket returns the bucket of now, resetting it when it is stale.
+func (b *breaker) bucket(now time.Time) *breakerBucket {
+	width := b.settings.Window / breakerBuckets
+	if width <= 0 {
+		width = 1
+	}
+	slot := now.Truncate(width)
+	bucket := &b.buckets[int(slot.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(slot) {
+		*bucket = breakerBucket{start: slot}
+	}
+	return bucket
+}
+
+// tripped returns why the breaker has to open, or "" if it stays closed.
+func (b *breaker) tripped(now time.Time) string {
+	var total, failures, timeouts int
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) >= b.settings.Window {
+			continue
+		}
+		total += bucket.total
+		failures += bucket.failures
+		timeouts += bucket.timeouts
+	}
+	if total < b.settings.MinRequests {
+		return ""
+	}
+	if rate := float64(failures) / float64(total); b.settings.ErrorRate > 0 && rate >= b.settings.ErrorRate {
+		return fmt.Sprintf("error rate %.2f", rate)
+	}
+	if rate := float64(timeouts) / float64(total); b.settings.TimeoutRate > 0 && rate >= b.settings.TimeoutRate {
+		return fmt.Sprintf("timeout rate %.2f", rate)
+	}
+	return ""
+}
+
+func (b *breaker) open(reason string, now time.Time) *ops.BreakerChange {
+	b.openedAt = now
+	return b.transition(ops.BreakerOpen, reason, now)
+}
+
+func (b *breaker) transition(to ops.BreakerState, reason string, now time.Time) *ops.BreakerChange {
+	change := &ops.BreakerChange{Class: b.class, From: b.state, To: to, Reason: reason, At: now}
+	b.state = to
+	b.probes = 0
+	b.succeeded = 0
+	if to == ops.BreakerClosed {
+		b.buckets = [breakerBuckets]breakerBucket{}
+	}
+	return change
+}
+
+// abandon gives back the probe taken by allow for a statement that did
+// not run.
+func (b *breaker) abandon() {
+	b.Lock()
+	defer b.Unlock()
+	if b.state == ops.BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *breaker) current() ops.BreakerState {
+	b.Lock()
+	defer b.Unlock()
+	return b.state
+}
+
+// isClusterFailure reports whether err means the cluster is unhealthy, as
+// opposed to a problem with the statement itself.
+func isClusterFailure(err error) bool {
+	return errors.Is(err, ops.ErrTimeout) || errors.Is(err, ops.ErrUnavailable) ||
+		errors.Is(err, ops.ErrOverloaded)
+}
+
+func (cn *conn) setBreakers(cfg BreakerConfig) {
+	breakers := make(map[string]*breaker)
+	for class, s := range map[string]BreakerSettings{ops.ClassRead: cfg.Reads, ops.ClassWrite: cfg.Writes, ops.ClassDDL: cfg.DDL} {
+		if s.Enabled {
+			breakers[class] = newBreaker(class, s, cn.breakerChanged)
+			cn.reportBreaker(class, ops.BreakerClosed)
+		}
+	}
+	cn.Lock()
+	defer cn.Unlock()
+	cn.breakers = breakers
+}
+
+func (cn *conn) breaker(class string) *breaker {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.breakers[class]
+}
+
+// breakerChanged reports a state transition to the metrics, the log and
+// the hooks implementing ops.BreakerObserver.
+func (cn *conn) breakerChanged(change ops.BreakerChange) {
+	cn.reportBreaker(change.Class, change.To)
+	level := ops.LevelWarn
+	if change.To == ops.BreakerClosed {
+		level = ops.LevelInfo
+	}
+	cn.log().Log(level, "circuit breaker "+string(change.To), ops.F("class", change.Class),
+		ops.F("reason", change.Reason))
+	for _, h := range cn.queryHooks() {
+		if o, ok := h.(ops.BreakerObserver); ok {
+			o.BreakerStateChanged(change)
+		}
+	}
+}
+
+func (cn *conn) reportBreaker(class string, state ops.BreakerState) {
+	m := cn.metricSink()
+	for _, s := range []ops.BreakerState{ops.BreakerClosed, ops.BreakerOpen, ops.BreakerHalfOpen} {
+		val := 0.0
+		if s == state {
+			val = 1
+		}
+		m.Set(ops.MetricBreakerState, ops.Labels{ops.LabelOpClass: class, ops.LabelState: string(s)}, val)
+	}
+}
+
+// checkBreaker returns the breaker a statement has to report its outcome
+// to, or an error of class ops.ErrCircuitOpen when the breaker of its
+// operation class rejects it.
+func (cn *conn) checkBreaker(info ops.QueryInfo) (*breaker, error) {
+	class := ops.OperationClass(info.Operation)
+	b := cn.breaker(class)
+	if b == nil {
+		return nil, nil
+	}
+	if !b.allow() {
+		cn.metricSink().Add(ops.MetricBreakerReject, ops.Labels{ops.LabelOpClass: class}, 1)
+		return nil, ops.NewOpError(info.Operation, info.Keyspace, info.Table, ops.ErrCircuitOpen,
+			"%s circuit breaker is %s", class, b.current())
+	}
+	return b, nil
+}
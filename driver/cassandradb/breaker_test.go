@@ -0,0 +1,92 @@
+package cassandradb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/metrics"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type breakerRecorder struct {
+	ops.QueryHookFuncs
+	sync.Mutex
+	changes []ops.BreakerChange
+}
+
+func (r *breakerRecorder) BreakerStateChanged(change ops.BreakerChange) {
+	r.Lock()
+	defer r.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	m := metrics.NewPrometheus()
+	rec := &breakerRecorder{}
+	cn := newConn(nil)
+	cn.setMetrics(m)
+	cn.addHooks(rec)
+	cn.setBreakers(BreakerConfig{Reads: BreakerSettings{Enabled: true, MinRequests: 4, ErrorRate: 0.5,
+		OpenFor: time.Minute}})
+	b := cn.breaker(ops.ClassRead)
+	require.NotNil(t, b)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	read := ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "users", Statement: "SELECT"}
+	for i := 0; i < 4; i++ {
+		assert.ErrorIs(t, cn.exec(context.Background(), read), ops.ErrUnavailable)
+	}
+	assert.Equal(t, ops.BreakerOpen, b.current())
+
+	err := cn.exec(context.Background(), read)
+	assert.ErrorIs(t, err, ops.ErrCircuitOpen)
+	assert.Equal(t, "circuit_open", ops.ErrorClass(err))
+	assert.Equal(t, 1.0, m.Value(ops.MetricBreakerReject, ops.Labels{ops.LabelOpClass: ops.ClassRead}))
+
+	// writes have no breaker
+	write := ops.QueryInfo{Operation: ops.OpInsert, Keyspace: "ks", Table: "users", Statement: "INSERT"}
+	assert.ErrorIs(t, cn.exec(context.Background(), write), ops.ErrUnavailable)
+
+	// after the open period a probe goes through, fails and opens the breaker again
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, cn.exec(context.Background(), read), ops.ErrUnavailable)
+	assert.Equal(t, ops.BreakerOpen, b.current())
+
+	// a successful probe closes it
+	now = now.Add(time.Minute)
+	require.True(t, b.allow())
+	assert.False(t, b.allow())
+	b.record(nil)
+	assert.Equal(t, ops.BreakerClosed, b.current())
+
+	var states []ops.BreakerState
+	for _, c := range rec.changes {
+		states = append(states, c.To)
+	}
+	assert.Equal(t, []ops.BreakerState{ops.BreakerOpen, ops.BreakerHalfOpen, ops.BreakerOpen,
+		ops.BreakerHalfOpen, ops.BreakerClosed}, states)
+	assert.Equal(t, 1.0, m.Value(ops.MetricBreakerState, ops.Labels{ops.LabelOpClass: ops.ClassRead,
+		ops.LabelState: string(ops.BreakerClosed)}))
+}
+
+func TestBreakerIgnoresStatementErrors(t *testing.T) {
+	b := newBreaker(ops.ClassRead, BreakerSettings{Enabled: true, MinRequests: 2, TimeoutRate: 0.5}, func(ops.BreakerChange) {})
+	for i := 0; i < 10; i++ {
+		require.True(t, b.allow())
+		b.record(&ops.OpError{Kind: ops.ErrInvalidQuery})
+	}
+	assert.Equal(t, ops.BreakerClosed, b.current())
+
+	require.True(t, b.allow())
+	b.record(&ops.OpError{Kind: ops.ErrTimeout})
+	assert.Equal(t, ops.BreakerClosed, b.current())
+	for i := 0; i < 10; i++ {
+		b.record(&ops.OpError{Kind: ops.ErrTimeout})
+	}
+	assert.Equal(t, ops.BreakerOpen, b.current())
+}
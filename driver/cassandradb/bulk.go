@@ -0,0 +1,315 @@
+package cassandradb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// BulkOptions controls a BulkWriter.
+type BulkOptions struct {
+	// Concurrency is the number of batches written at the same time,
+	// defaults to 4.
+	Concurrency int
+	// BatchSize is the maximum number of rows of a batch, defaults to 20.
+	// The rows of a batch all belong to the same partition.
+	BatchSize int
+	// FlushInterval is how long rows wait for their batch to fill up,
+	// defaults to 100ms.
+	FlushInterval time.Duration
+	// MaxPending is the number of rows accepted but not written yet above
+	// which Write blocks, defaults to Concurrency * BatchSize * 4.
+	MaxPending int
+	// Retries is the number of times a batch failing because the cluster is
+	// unavailable, overloaded or timing out is written again, defaults to 3
+	// and a negative value disables retries. Batches of inserts are
+	// idempotent, other failures are not retried.
+	Retries int
+	// RetryBackoff is the wait before the first retry of a batch, it grows
+	// with every retry. Defaults to 100ms.
+	RetryBackoff time.Duration
+	// DeadLetter is called with every row that could not be written and
+	// the error of its batch. It may be called from several goroutines.
+	DeadLetter func(row interface{}, err error)
+}
+
+func (o BulkOptions) withDefaults() BulkOptions {
+	if o.Concurrency < 1 {
+		o.Concurrency = 4
+	}
+	if o.BatchSize < 1 {
+		o.BatchSize = 20
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 100 * time.Millisecond
+	}
+	if o.MaxPending < 1 {
+		o.MaxPending = o.Concurrency * o.BatchSize * 4
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	} else if o.Retries == 0 {
+		o.Retries = 3
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 100 * time.Millisecond
+	}
+	return o
+}
+
+// BulkReport summarizes the rows handled by a BulkWriter.
+type BulkReport struct {
+	Written int64
+	Failed  int64
+	// Retried counts the rows of the batches written again
+	Retried  int64
+	Batches  int64
+	Duration time.Duration
+}
+
+// BulkWriter inserts rows in unlogged batches grouped by partition. Rows
+// are written in the background, Close waits for them and returns the
+// report. A BulkWriter is safe for concurrent use. The lifecycle hooks of
+// the model are not called.
+type BulkWriter struct {
+	table   *Table
+	opts    BulkOptions
+	start   time.Time
+	slots   chan struct{}
+	batches chan bulkBatch
+	workers sync.WaitGroup
+
+	// closeMu is held by Write while it adds a row, Close takes it to wait
+	// for the rows being added
+	closeMu sync.RWMutex
+	closed  bool
+	stop    chan struct{}
+	flushed chan struct{}
+
+	mu       sync.Mutex
+	pending  map[string]*bulkBatch
+	report   BulkReport
+	firstErr error
+}
+
+type bulkBatch struct {
+	stmt   string
+	rows   []interface{}
+	values [][]interface{}
+}
+
+// NewBulkWriter returns a BulkWriter inserting rows into the table. Its
+// statements run with the context of the table.
+func (t *Table) NewBulkWriter(opts BulkOptions) *BulkWriter {
+	opts = opts.withDefaults()
+	w := &BulkWriter{
+		table:   t,
+		opts:    opts,
+		start:   time.Now(),
+		slots:   make(chan struct{}, opts.MaxPending),
+		batches: make(chan bulkBatch, opts.Concurrency),
+		stop:    make(chan struct{}),
+		flushed: make(chan struct{}),
+		pending: make(map[string]*bulkBatch),
+	}
+	for i := 0; i < opts.Concurrency; i++ {
+		w.workers.Add(1)
+		go w.work()
+	}
+	go w.flusher()
+	return w
+}
+
+// Write queues row for insertion, a value of or a pointer to the data
+// model of the table. It blocks while MaxPending rows are waiting to be
+// written, until the context of the table is done. Rows that cannot be
+// turned into an insert go to the dead letter callback right away.
+func (w *BulkWriter) Write(row interface{}) error {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		return w.table.invalidQuery(ops.OpBatch, "bulk writer is closed")
+	}
+
+	ctx := w.table.context()
+	select {
+	case w.slots <- struct{}{}:
+	case <-ctx.Done():
+		return wrapError(w.table.queryInfo(ops.OpBatch, ""), ctx.Err())
+	}
+
+	stmt, values, err := w.table.insertStatement(row, false)
+	if err != nil {
+		w.fail(bulkBatch{rows: []interface{}{row}}, err)
+		<-w.slots
+		return nil
+	}
+	partition := w.partition(values)
+
+	w.mu.Lock()
+	b, ok := w.pending[partition]
+	if !ok {
+		b = &bulkBatch{stmt: stmt}
+		w.pending[partition] = b
+	}
+	b.rows = append(b.rows, row)
+	b.values = append(b.values, values)
+	full := len(b.rows) >= w.opts.BatchSize
+	if full {
+		delete(w.pending, partition)
+	}
+	w.mu.Unlock()
+
+	if full {
+		w.batches <- *b
+	}
+	return nil
+}
+
+// WriteFrom writes the rows received on rows until it is closed.
+func (w *BulkWriter) WriteFrom(rows <-chan interface{}) error {
+	for row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the rows still waiting for their batch to fill up, waits
+// for every row to be written and returns the report. The error is the
+// error of the first row that failed.
+func (w *BulkWriter) Close() (BulkReport, error) {
+	w.closeMu.Lock()
+	if w.closed {
+		w.closeMu.Unlock()
+		return w.summary()
+	}
+	w.closed = true
+	w.closeMu.Unlock()
+
+	close(w.stop)
+	<-w.flushed
+	w.flush()
+	close(w.batches)
+	w.workers.Wait()
+	return w.summary()
+}
+
+func (w *BulkWriter) summary() (BulkReport, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	report := w.report
+	report.Duration = time.Since(w.start)
+	return report, w.firstErr
+}
+
+// partition returns the partition key of the insert values of a row.
+func (w *BulkWriter) partition(values []interface{}) string {
+	var key []string
+	for i, entity := range w.table.entities {
+		if entity.primaryKey {
+			key = append(key, fmt.Sprintf("%#v", values[i]))
+		}
+	}
+	return strings.Join(key, "\x00")
+}
+
+// flusher writes the batches that are not full every FlushInterval.
+func (w *BulkWriter) flusher() {
+	defer close(w.flushed)
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *BulkWriter) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string]*bulkBatch)
+	w.mu.Unlock()
+	for _, b := range pending {
+		w.batches <- *b
+	}
+}
+
+func (w *BulkWriter) work() {
+	defer w.workers.Done()
+	for b := range w.batches {
+		err := w.write(b)
+		w.mu.Lock()
+		w.report.Batches++
+		if err == nil {
+			w.report.Written += int64(len(b.rows))
+		}
+		w.mu.Unlock()
+		if err != nil {
+			w.fail(b, err)
+		}
+		for range b.rows {
+			<-w.slots
+		}
+	}
+}
+
+// write writes b, retrying failures of the cluster.
+func (w *BulkWriter) write(b bulkBatch) error {
+	ctx := w.table.context()
+	stmts := make([]string, len(b.rows))
+	for i := range stmts {
+		stmts[i] = b.stmt
+	}
+	info := w.table.queryInfo(ops.OpBatch, "BEGIN UNLOGGED BATCH "+b.stmt+" APPLY BATCH;")
+	for attempt := 1; ; attempt++ {
+		info.Attempt = attempt
+		var err error
+		if len(b.rows) == 1 {
+			single := w.table.queryInfo(ops.OpInsert, b.stmt)
+			single.Attempt = attempt
+			err = w.table.conn.exec(ctx, single, b.values[0]...)
+		} else {
+			err = w.table.conn.batch(ctx, info, gocql.UnloggedBatch, stmts, b.values)
+		}
+		if err == nil || attempt > w.opts.Retries || !isClusterFailure(err) {
+			return err
+		}
+		w.mu.Lock()
+		w.report.Retried += int64(len(b.rows))
+		w.mu.Unlock()
+
+		timer := time.NewTimer(w.opts.RetryBackoff * time.Duration(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// fail counts the rows of b as failed and hands them to the dead letter
+// callback.
+func (w *BulkWriter) fail(b bulkBatch, err error) {
+	w.mu.Lock()
+	w.report.Failed += int64(len(b.rows))
+	if w.firstErr == nil {
+		w.firstErr = err
+	}
+	w.mu.Unlock()
+	if w.opts.DeadLetter != nil {
+		for _, row := range b.rows {
+			w.opts.DeadLetter(row, err)
+		}
+	}
+}
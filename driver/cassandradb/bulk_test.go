@@ -0,0 +1,87 @@
+package cassandradb
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkWriterGroupsByPartition(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	var mu sync.Mutex
+	var dead []interface{}
+	w := tbl.NewBulkWriter(BulkOptions{BatchSize: 3, FlushInterval: time.Hour, Retries: 1,
+		RetryBackoff: time.Millisecond, DeadLetter: func(row interface{}, err error) {
+			if _, ok := row.(string); ok {
+				assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+			} else {
+				assert.ErrorIs(t, err, ops.ErrUnavailable)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			dead = append(dead, row)
+		}})
+
+	rows := make(chan interface{})
+	go func() {
+		defer close(rows)
+		for i := 0; i < 4; i++ {
+			rows <- pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01", Hour: i}
+		}
+		for i := 0; i < 3; i++ {
+			rows <- &pageView{Tenant: "acme", Region: "us", Day: "2024-05-01", Hour: i}
+		}
+	}()
+	assert.NoError(t, w.Write("not a row"))
+	require.NoError(t, w.WriteFrom(rows))
+
+	report, err := w.Close()
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+	assert.Equal(t, int64(0), report.Written)
+	assert.Equal(t, int64(8), report.Failed)
+	assert.Equal(t, int64(7), report.Retried)
+	assert.Equal(t, int64(3), report.Batches)
+	assert.Len(t, dead, 8)
+
+	// two full batches and the row left over, each written twice
+	require.Len(t, *stmts, 6)
+	batches := 0
+	for _, stmt := range *stmts {
+		if strings.HasPrefix(stmt, "BEGIN UNLOGGED BATCH INSERT INTO ks.views ") {
+			batches++
+		} else {
+			assert.True(t, strings.HasPrefix(stmt, "INSERT INTO ks.views "), stmt)
+		}
+	}
+	assert.Equal(t, 4, batches)
+
+	assert.ErrorIs(t, w.Write(pageView{}), ops.ErrInvalidQuery)
+}
+
+func TestBulkWriterBackpressure(t *testing.T) {
+	tbl, _ := newRecordingTable(t, pageView{})
+	release := make(chan struct{})
+	tbl.conn.addHooks(ops.QueryHookFuncs{BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+		<-release
+		return ctx, nil
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	w := tbl.WithContext(ctx).NewBulkWriter(BulkOptions{BatchSize: 1, MaxPending: 2, Retries: -1})
+
+	require.NoError(t, w.Write(pageView{Tenant: "a", Region: "eu", Day: "2024-05-01"}))
+	require.NoError(t, w.Write(pageView{Tenant: "b", Region: "eu", Day: "2024-05-01"}))
+	assert.ErrorIs(t, w.Write(pageView{Tenant: "c", Region: "eu", Day: "2024-05-01"}), ops.ErrTimeout)
+
+	close(release)
+	report, err := w.Close()
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), report.Failed)
+	assert.Equal(t, int64(0), report.Retried)
+}
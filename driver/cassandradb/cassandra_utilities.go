@@ -3,7 +3,7 @@ package cassandradb
 import (
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
@@ -25,26 +25,25 @@ type CassandraQuery struct {
 	Values []interface{}
 }
 
+// CreateIndex creates a secondary index on the column index of a table.
 func CreateIndex(dbSession *gocql.Session, keyspaceName, tableName, index string) error {
-	idx := tableName + index + "_index"
-	queryStr := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s.%s (%s)", idx, keyspaceName, tableName, index)
-	log.Printf("query :: %s\n", queryStr)
-	cassQuery, err := CreateQuery(dbSession, queryStr)
+	cassQuery, err := CreateQuery(dbSession, createIndexStmt(keyspaceName, tableName, index))
 	if err != nil {
 		return err
 	}
-	if err = ExecQuery(cassQuery); err != nil {
-		log.Printf("Create index query failed: %s :: %v", queryStr, err)
-	}
-	log.Printf("created index: %s", index)
-	return nil
+	return ExecQuery(cassQuery)
+}
+
+func createIndexStmt(keyspaceName, tableName, index string) string {
+	idx := tableName + index + "_index"
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s.%s (%s)", idx, keyspaceName, tableName, index)
 }
 
 // CreateQuery is a helper function that constructs a gocql.Query object from
 // the input query string and values varargs.
 func CreateQuery(dbSession *gocql.Session, stmt string, values ...interface{}) (*gocql.Query, error) {
 
-	return dbSession.Query(stmt, values), nil
+	return dbSession.Query(stmt, values...), nil
 }
 
 // ScanQuery executes the given query on the Cassandra DB, copies the columns of
@@ -56,8 +55,7 @@ func ScanQuery(dbSession *gocql.Session, cassQuery *gocql.Query,
 	if dbSession == nil {
 		return errors.New("invalid DB connection")
 	}
-	if err := (*cassQuery).Scan(results); err != nil {
-		log.Printf("error executing query: ", err)
+	if err := (*cassQuery).Scan(results...); err != nil {
 		return err
 	}
 	return nil
@@ -67,8 +65,146 @@ func ScanQuery(dbSession *gocql.Session, cassQuery *gocql.Query,
 // rows. It is a wrapper around gocql.Query.Exec() and is used by SetDB().
 func ExecQuery(cassQuery *gocql.Query) error {
 	if err := (*cassQuery).Exec(); err != nil {
-		log.Printf("error executing query: %v :: %v", *cassQuery, err)
 		return err
 	}
 	return nil
 }
+
+// redactCQL replaces the string, numeric, uuid and blob literals and the
+// elements of IN lists in a CQL statement with '?' so statements can be
+// logged without user data.
+func redactCQL(stmt string) string {
+	var buf strings.Builder
+	buf.Grow(len(stmt))
+	prevIdent := false
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		switch {
+		case ch == '\'':
+			i = skipQuoted(stmt, i)
+			buf.WriteByte('?')
+			prevIdent = false
+		case !prevIdent && isUUIDAt(stmt, i):
+			i += uuidLen - 1
+			buf.WriteByte('?')
+			prevIdent = true
+		case !prevIdent && (isDigit(ch) || (ch == '-' && i+1 < len(stmt) && isDigit(stmt[i+1]))):
+			for i++; i < len(stmt) && isLiteralChar(stmt[i]); i++ {
+			}
+			i--
+			buf.WriteByte('?')
+			prevIdent = true
+		case !prevIdent && isIdentChar(ch):
+			j := i
+			for j < len(stmt) && isIdentChar(stmt[j]) {
+				j++
+			}
+			word := stmt[i:j]
+			buf.WriteString(word)
+			i = j - 1
+			prevIdent = true
+			if strings.EqualFold(word, "in") {
+				k := j
+				for k < len(stmt) && stmt[k] == ' ' {
+					k++
+				}
+				if k < len(stmt) && stmt[k] == '(' {
+					buf.WriteString(stmt[j:k])
+					i = redactList(&buf, stmt, k)
+					prevIdent = false
+				}
+			}
+		default:
+			buf.WriteByte(ch)
+			prevIdent = isIdentChar(ch)
+		}
+	}
+	return buf.String()
+}
+
+// skipQuoted returns the index of the quote closing the string literal
+// opened at i, a doubled quote is an escaped quote.
+func skipQuoted(stmt string, i int) int {
+	for i++; i < len(stmt); i++ {
+		if stmt[i] == '\'' {
+			if i+1 < len(stmt) && stmt[i+1] == '\'' {
+				i++
+				continue
+			}
+			break
+		}
+	}
+	return i
+}
+
+// redactList writes the IN list opened at i with every element replaced by
+// '?' and returns the index of the closing parenthesis.
+func redactList(buf *strings.Builder, stmt string, i int) int {
+	buf.WriteByte('(')
+	depth, empty := 1, true
+	for i++; i < len(stmt); i++ {
+		switch ch := stmt[i]; {
+		case ch == '\'':
+			i = skipQuoted(stmt, i)
+			empty = false
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				if !empty {
+					buf.WriteByte('?')
+				}
+				buf.WriteByte(')')
+				return i
+			}
+		case ch == ',' && depth == 1:
+			buf.WriteString("?, ")
+		case ch != ' ':
+			empty = false
+		}
+	}
+	if !empty {
+		buf.WriteByte('?')
+	}
+	return i
+}
+
+const uuidLen = 36
+
+// isUUIDAt reports whether a uuid literal starts at i.
+func isUUIDAt(stmt string, i int) bool {
+	if len(stmt)-i < uuidLen || (i+uuidLen < len(stmt) && isIdentChar(stmt[i+uuidLen])) {
+		return false
+	}
+	for j := 0; j < uuidLen; j++ {
+		ch := stmt[i+j]
+		switch j {
+		case 8, 13, 18, 23:
+			if ch != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(ch) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func isIdentChar(ch byte) bool {
+	return isDigit(ch) || ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isLiteralChar(ch byte) bool {
+	return isIdentChar(ch) || ch == '.' || ch == '-' || ch == '+' || ch == ':'
+}
@@ -0,0 +1,51 @@
+package cassandradb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactCQL(t *testing.T) {
+	for _, tc := range []struct {
+		name, stmt, want string
+	}{
+		{"quoted string", "SELECT * FROM ks.users WHERE name = 'amy';",
+			"SELECT * FROM ks.users WHERE name = ?;"},
+		{"escaped quote", "UPDATE ks.users SET bio = 'it''s me, ''amy''' WHERE id = 1;",
+			"UPDATE ks.users SET bio = ? WHERE id = ?;"},
+		{"numbers", "SELECT * FROM ks.t1 WHERE a = 42 AND b = -3.5 AND c = 1e10 LIMIT 10;",
+			"SELECT * FROM ks.t1 WHERE a = ? AND b = ? AND c = ? LIMIT ?;"},
+		{"uuid", "DELETE FROM ks.users WHERE id = f47ac10b-58cc-4372-a567-0e02b2c3d479;",
+			"DELETE FROM ks.users WHERE id = ?;"},
+		{"upper case uuid", "SELECT * FROM ks.users WHERE id = F47AC10B-58CC-4372-A567-0E02B2C3D479",
+			"SELECT * FROM ks.users WHERE id = ?"},
+		{"uuid starting with a digit", "SELECT * FROM ks.users WHERE id = 123e4567-e89b-12d3-a456-426614174000",
+			"SELECT * FROM ks.users WHERE id = ?"},
+		{"blob", "INSERT INTO ks.files (id, data) VALUES (1, 0xcafe01);",
+			"INSERT INTO ks.files (id, data) VALUES (?, ?);"},
+		{"unquoted in list", "DELETE FROM ks.users WHERE id in (abc, def);",
+			"DELETE FROM ks.users WHERE id in (?, ?);"},
+		{"quoted in list", "SELECT * FROM ks.users WHERE id IN ('a,b', 'c''d') AND x = 1",
+			"SELECT * FROM ks.users WHERE id IN (?, ?) AND x = ?"},
+		{"tuple in list", "SELECT * FROM ks.t WHERE (a, b) IN ((1, 2), (3, 4))",
+			"SELECT * FROM ks.t WHERE (a, b) IN (?, ?)"},
+		{"uuid in list", "SELECT * FROM ks.t WHERE id in (f47ac10b-58cc-4372-a567-0e02b2c3d479)",
+			"SELECT * FROM ks.t WHERE id in (?)"},
+		{"bind markers", "SELECT id, name FROM ks.users WHERE id = ? AND day IN ?;",
+			"SELECT id, name FROM ks.users WHERE id = ? AND day IN ?;"},
+		{"identifiers", "SELECT writetime(name), ttl(name) FROM ks.users_2024 WHERE token(id) > ?",
+			"SELECT writetime(name), ttl(name) FROM ks.users_2024 WHERE token(id) > ?"},
+	} {
+		assert.Equal(t, tc.want, redactCQL(tc.stmt), tc.name)
+	}
+}
+
+func TestDebugQueryLog(t *testing.T) {
+	stmt := "SELECT * FROM ks.users WHERE id = f47ac10b-58cc-4372-a567-0e02b2c3d479 AND name = 'amy'"
+	cn := newConn(nil)
+	assert.Equal(t, "SELECT * FROM ks.users WHERE id = ? AND name = ?", cn.statement(stmt))
+
+	cn.setLogger(nil, true)
+	assert.Equal(t, stmt, cn.statement(stmt))
+}
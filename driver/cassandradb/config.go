@@ -0,0 +1,264 @@
+package cassandradb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// retry policy types
+const (
+	RetrySimple      = "simple"
+	RetryExponential = "exponential"
+)
+
+// host selection policies
+const (
+	HostPolicyRoundRobin = "roundrobin"
+	HostPolicyDCAware    = "dcaware"
+	HostPolicyTokenAware = "tokenaware"
+)
+
+// RetryPolicyConfig selects the gocql retry policy used by a Client.
+// Min and Max are only used by the exponential backoff policy. NumRetries
+// defaults to 4, a negative value disables retries.
+type RetryPolicyConfig struct {
+	Type       string        `toml:"type"`
+	NumRetries int           `toml:"num_retries"`
+	Min        time.Duration `toml:"min"`
+	Max        time.Duration `toml:"max"`
+}
+
+// HostSelectionConfig selects the gocql host selection policy.
+// LocalDC is required by the dcaware policy; the tokenaware policy falls
+// back to dcaware when LocalDC is set and to round robin otherwise.
+type HostSelectionConfig struct {
+	Policy  string `toml:"policy"`
+	LocalDC string `toml:"local_dc"`
+}
+
+// TLSConfig enables TLS on the connections to the cluster. The files are
+// PEM encoded, CertFile and KeyFile set a client certificate. The host name
+// of the nodes is verified unless InsecureSkipVerify is set.
+type TLSConfig struct {
+	Enabled            bool   `toml:"enabled"`
+	CAFile             string `toml:"ca_file"`
+	CertFile           string `toml:"cert_file"`
+	KeyFile            string `toml:"key_file"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
+// AuthConfig sets the credentials of the Cassandra password authenticator,
+// no authenticator is used when Username is empty.
+type AuthConfig struct {
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// Config holds the connection settings of a Client.
+// Zero values are replaced by the values in DefaultConfig. ConnectTimeout
+// and Timeout take a negative value for no timeout, ReconnectInterval a
+// negative value to turn off the periodic reconnect of down hosts.
+type Config struct {
+	ServerList        string              `toml:"server_list"`
+	Port              int                 `toml:"port"`
+	KeySpace          string              `toml:"keyspace"`
+	Consistency       string              `toml:"consistency"`
+	ConnectTimeout    time.Duration       `toml:"connect_timeout"`
+	Timeout           time.Duration       `toml:"timeout"`
+	NumConns          int                 `toml:"num_conns"`
+	RetryPolicy       RetryPolicyConfig   `toml:"retry_policy"`
+	ReconnectInterval time.Duration       `toml:"reconnect_interval"`
+	ProtoVersion      int                 `toml:"proto_version"`
+	HostSelection     HostSelectionConfig `toml:"host_selection"`
+	Supervisor        SupervisorConfig    `toml:"supervisor"`
+	TLS               TLSConfig           `toml:"tls"`
+	Auth              AuthConfig          `toml:"auth"`
+	// SlowQuery records statements that run longer than a threshold.
+	SlowQuery SlowQueryConfig `toml:"slow_query"`
+	// Limits sets client side rate limits and in-flight caps.
+	Limits LimitsConfig `toml:"limits"`
+	// Breaker sets the circuit breakers per operation class.
+	Breaker BreakerConfig `toml:"breaker"`
+	// DebugQueryLog logs statements with their values instead of redacting them.
+	DebugQueryLog bool       `toml:"debug_query_log"`
+	Logger        ops.Logger `toml:"-"`
+	// QueryHooks are called around every statement run by the client.
+	QueryHooks []ops.QueryHook `toml:"-"`
+	// Metrics receives query and connection metrics.
+	Metrics ops.Metrics `toml:"-"`
+	// TracerProvider creates the spans of every operation, no spans are
+	// recorded when unset.
+	TracerProvider trace.TracerProvider `toml:"-"`
+	// KeyProvider supplies the keys of the columns tagged encrypt.
+	KeyProvider KeyProvider `toml:"-"`
+}
+
+// DefaultConfig is used for any setting that is not supplied to NewClientWithConfig.
+var DefaultConfig = Config{
+	ServerList:     "localhost",
+	Port:           9042,
+	Consistency:    "ONE",
+	ConnectTimeout: 600 * time.Millisecond,
+	Timeout:        900 * time.Millisecond,
+	NumConns:       2,
+	RetryPolicy: RetryPolicyConfig{
+		Type:       RetrySimple,
+		NumRetries: 4,
+	},
+	ReconnectInterval: 60 * time.Second,
+	HostSelection: HostSelectionConfig{
+		Policy: HostPolicyRoundRobin,
+	},
+	Supervisor: SupervisorConfig{
+		PingInterval:     10 * time.Second,
+		FailureThreshold: 3,
+		BackoffMin:       100 * time.Millisecond,
+		BackoffMax:       30 * time.Second,
+	},
+}
+
+// withDefaults returns a copy of the config with the zero values filled
+// in from DefaultConfig.
+func (cfg Config) withDefaults() Config {
+	if cfg.ServerList == "" {
+		cfg.ServerList = DefaultConfig.ServerList
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultConfig.Port
+	}
+	if cfg.Consistency == "" {
+		cfg.Consistency = DefaultConfig.Consistency
+	}
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = DefaultConfig.ConnectTimeout
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	if cfg.NumConns == 0 {
+		cfg.NumConns = DefaultConfig.NumConns
+	}
+	if cfg.RetryPolicy.Type == "" {
+		cfg.RetryPolicy.Type = DefaultConfig.RetryPolicy.Type
+	}
+	if cfg.RetryPolicy.NumRetries == 0 {
+		cfg.RetryPolicy.NumRetries = DefaultConfig.RetryPolicy.NumRetries
+	}
+	if cfg.ReconnectInterval == 0 {
+		cfg.ReconnectInterval = DefaultConfig.ReconnectInterval
+	}
+	if cfg.HostSelection.Policy == "" {
+		cfg.HostSelection.Policy = DefaultConfig.HostSelection.Policy
+	}
+	if cfg.Supervisor.PingInterval == 0 {
+		cfg.Supervisor.PingInterval = DefaultConfig.Supervisor.PingInterval
+	}
+	if cfg.Supervisor.FailureThreshold == 0 {
+		cfg.Supervisor.FailureThreshold = DefaultConfig.Supervisor.FailureThreshold
+	}
+	if cfg.Supervisor.BackoffMin == 0 {
+		cfg.Supervisor.BackoffMin = DefaultConfig.Supervisor.BackoffMin
+	}
+	if cfg.Supervisor.BackoffMax == 0 {
+		cfg.Supervisor.BackoffMax = DefaultConfig.Supervisor.BackoffMax
+	}
+	return cfg
+}
+
+// hosts splits the comma separated server list.
+func (cfg Config) hosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(cfg.ServerList, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// newClusterConfig translates the config into a gocql cluster configuration.
+func newClusterConfig(cfg Config) (*gocql.ClusterConfig, error) {
+	cfg = cfg.withDefaults()
+
+	consistency, err := gocql.ParseConsistencyWrapper(cfg.Consistency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consistency %q: %v", cfg.Consistency, err)
+	}
+
+	cluster := gocql.NewCluster(cfg.hosts()...)
+	cluster.Port = cfg.Port
+	cluster.Consistency = consistency
+	cluster.ConnectTimeout = orNone(cfg.ConnectTimeout)
+	cluster.Timeout = orNone(cfg.Timeout)
+	cluster.NumConns = cfg.NumConns
+	cluster.ReconnectInterval = orNone(cfg.ReconnectInterval)
+	cluster.ProtoVersion = cfg.ProtoVersion
+
+	numRetries := cfg.RetryPolicy.NumRetries
+	if numRetries < 0 {
+		numRetries = 0
+	}
+	switch strings.ToLower(cfg.RetryPolicy.Type) {
+	case RetrySimple:
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: numRetries}
+	case RetryExponential:
+		cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
+			NumRetries: numRetries,
+			Min:        cfg.RetryPolicy.Min,
+			Max:        cfg.RetryPolicy.Max,
+		}
+	default:
+		return nil, fmt.Errorf("invalid retry policy: %s", cfg.RetryPolicy.Type)
+	}
+
+	localDC := cfg.HostSelection.LocalDC
+	switch strings.ToLower(cfg.HostSelection.Policy) {
+	case HostPolicyRoundRobin:
+		cluster.PoolConfig.HostSelectionPolicy = gocql.RoundRobinHostPolicy()
+	case HostPolicyDCAware:
+		if localDC == "" {
+			return nil, fmt.Errorf("host selection policy %s requires a local dc", HostPolicyDCAware)
+		}
+		cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(localDC)
+	case HostPolicyTokenAware:
+		fallback := gocql.RoundRobinHostPolicy()
+		if localDC != "" {
+			fallback = gocql.DCAwareRoundRobinPolicy(localDC)
+		}
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(fallback)
+	default:
+		return nil, fmt.Errorf("invalid host selection policy: %s", cfg.HostSelection.Policy)
+	}
+
+	if cfg.TLS.Enabled {
+		if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+			return nil, fmt.Errorf("tls needs both a cert_file and a key_file for a client certificate")
+		}
+		cluster.SslOpts = &gocql.SslOptions{
+			CaPath:                 cfg.TLS.CAFile,
+			CertPath:               cfg.TLS.CertFile,
+			KeyPath:                cfg.TLS.KeyFile,
+			EnableHostVerification: !cfg.TLS.InsecureSkipVerify,
+		}
+	}
+	if cfg.Auth.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: cfg.Auth.Username,
+			Password: cfg.Auth.Password,
+		}
+	}
+	return cluster, nil
+}
+
+// orNone returns d, or zero, which gocql takes as none, when d is negative.
+func orNone(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,122 @@
+package cassandradb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterConfigDefaults(t *testing.T) {
+	cluster, err := newClusterConfig(Config{ServerList: "10.0.0.1, 10.0.0.2,"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cluster.Hosts)
+	assert.Equal(t, DefaultConfig.Port, cluster.Port)
+	assert.Equal(t, gocql.One, cluster.Consistency)
+	assert.Equal(t, DefaultConfig.ConnectTimeout, cluster.ConnectTimeout)
+	assert.Equal(t, DefaultConfig.Timeout, cluster.Timeout)
+	assert.Equal(t, DefaultConfig.NumConns, cluster.NumConns)
+	assert.Equal(t, DefaultConfig.ReconnectInterval, cluster.ReconnectInterval)
+	assert.Equal(t, &gocql.SimpleRetryPolicy{NumRetries: 4}, cluster.RetryPolicy)
+	assert.Nil(t, cluster.SslOpts)
+	assert.Nil(t, cluster.Authenticator)
+}
+
+func TestClusterConfigDisabledSettings(t *testing.T) {
+	cluster, err := newClusterConfig(Config{
+		ConnectTimeout:    -1,
+		Timeout:           -1,
+		ReconnectInterval: -1,
+		RetryPolicy:       RetryPolicyConfig{NumRetries: -1},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, cluster.ConnectTimeout)
+	assert.Zero(t, cluster.Timeout)
+	assert.Zero(t, cluster.ReconnectInterval)
+	assert.Equal(t, &gocql.SimpleRetryPolicy{NumRetries: 0}, cluster.RetryPolicy)
+}
+
+func TestClusterConfigRetryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		retry RetryPolicyConfig
+		want  gocql.RetryPolicy
+	}{
+		{RetryPolicyConfig{Type: RetrySimple, NumRetries: 2}, &gocql.SimpleRetryPolicy{NumRetries: 2}},
+		{RetryPolicyConfig{Type: "SIMPLE", NumRetries: -1}, &gocql.SimpleRetryPolicy{NumRetries: 0}},
+		{RetryPolicyConfig{Type: RetryExponential, NumRetries: 3, Min: time.Millisecond, Max: time.Second},
+			&gocql.ExponentialBackoffRetryPolicy{NumRetries: 3, Min: time.Millisecond, Max: time.Second}},
+	} {
+		cluster, err := newClusterConfig(Config{RetryPolicy: tc.retry})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, cluster.RetryPolicy)
+	}
+	_, err := newClusterConfig(Config{RetryPolicy: RetryPolicyConfig{Type: "forever"}})
+	assert.Error(t, err)
+}
+
+func TestClusterConfigHostSelection(t *testing.T) {
+	typeOf := func(p gocql.HostSelectionPolicy) reflect.Type { return reflect.TypeOf(p) }
+	for _, tc := range []struct {
+		hosts HostSelectionConfig
+		want  gocql.HostSelectionPolicy
+	}{
+		{HostSelectionConfig{Policy: HostPolicyRoundRobin}, gocql.RoundRobinHostPolicy()},
+		{HostSelectionConfig{Policy: HostPolicyDCAware, LocalDC: "dc1"}, gocql.DCAwareRoundRobinPolicy("dc1")},
+		{HostSelectionConfig{Policy: "TokenAware"}, gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())},
+		{HostSelectionConfig{Policy: HostPolicyTokenAware, LocalDC: "dc1"},
+			gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy("dc1"))},
+	} {
+		cluster, err := newClusterConfig(Config{HostSelection: tc.hosts})
+		require.NoError(t, err)
+		assert.Equal(t, typeOf(tc.want), typeOf(cluster.PoolConfig.HostSelectionPolicy), tc.hosts.Policy)
+	}
+	for _, hosts := range []HostSelectionConfig{{Policy: HostPolicyDCAware}, {Policy: "nearest"}} {
+		_, err := newClusterConfig(Config{HostSelection: hosts})
+		assert.Error(t, err, hosts.Policy)
+	}
+}
+
+func TestClusterConfigConsistency(t *testing.T) {
+	for name, want := range map[string]gocql.Consistency{
+		"ONE":          gocql.One,
+		"quorum":       gocql.Quorum,
+		"LOCAL_QUORUM": gocql.LocalQuorum,
+		"EACH_QUORUM":  gocql.EachQuorum,
+		"ALL":          gocql.All,
+	} {
+		cluster, err := newClusterConfig(Config{Consistency: name})
+		require.NoError(t, err, name)
+		assert.Equal(t, want, cluster.Consistency, name)
+	}
+	_, err := newClusterConfig(Config{Consistency: "MOST"})
+	assert.Error(t, err)
+}
+
+func TestClusterConfigTLSAndAuth(t *testing.T) {
+	cluster, err := newClusterConfig(Config{
+		TLS:  TLSConfig{Enabled: true, CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client.key"},
+		Auth: AuthConfig{Username: "app", Password: "secret"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, cluster.SslOpts)
+	assert.Equal(t, "ca.pem", cluster.SslOpts.CaPath)
+	assert.Equal(t, "client.pem", cluster.SslOpts.CertPath)
+	assert.Equal(t, "client.key", cluster.SslOpts.KeyPath)
+	assert.True(t, cluster.SslOpts.EnableHostVerification)
+	assert.Equal(t, gocql.PasswordAuthenticator{Username: "app", Password: "secret"}, cluster.Authenticator)
+
+	cluster, err = newClusterConfig(Config{TLS: TLSConfig{Enabled: true, InsecureSkipVerify: true}})
+	require.NoError(t, err)
+	assert.False(t, cluster.SslOpts.EnableHostVerification)
+
+	// files without Enabled leave TLS off
+	cluster, err = newClusterConfig(Config{TLS: TLSConfig{CAFile: "ca.pem"}})
+	require.NoError(t, err)
+	assert.Nil(t, cluster.SslOpts)
+
+	_, err = newClusterConfig(Config{TLS: TLSConfig{Enabled: true, CertFile: "client.pem"}})
+	assert.Error(t, err)
+}
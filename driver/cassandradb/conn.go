@@ -0,0 +1,182 @@
+package cassandradb
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// conn is the connection state shared by a Client and every KeySpace and
+// Table created from it, so that a reconnect is picked up by all of them.
+type conn struct {
+	sync.RWMutex
+	dbSession *gocql.Session
+	logger    ops.Logger
+	metrics   ops.Metrics
+	spans     trace.Tracer
+	slow      SlowQueryConfig
+	traces    *traceLimiter
+	limits    *limiter
+	breakers  map[string]*breaker
+	hooks     []ops.QueryHook
+	keys      KeyProvider
+	// debugQueries logs statements with their values instead of redacting them
+	debugQueries bool
+}
+
+func newConn(dbSession *gocql.Session) *conn {
+	return &conn{dbSession: dbSession, logger: ops.NopLogger, metrics: ops.NopMetrics, spans: noopTracer}
+}
+
+func (cn *conn) session() *gocql.Session {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.dbSession
+}
+
+// setSession swaps in a new session and returns the previous one.
+func (cn *conn) setSession(s *gocql.Session) *gocql.Session {
+	cn.Lock()
+	defer cn.Unlock()
+	old := cn.dbSession
+	cn.dbSession = s
+	return old
+}
+
+func (cn *conn) setLogger(logger ops.Logger, debugQueries bool) {
+	if logger == nil {
+		logger = ops.NopLogger
+	}
+	cn.Lock()
+	defer cn.Unlock()
+	cn.logger = logger
+	cn.debugQueries = debugQueries
+}
+
+func (cn *conn) addHooks(hooks ...ops.QueryHook) {
+	cn.Lock()
+	defer cn.Unlock()
+	// copy on write, statements in flight keep the slice they started with
+	cn.hooks = append(append([]ops.QueryHook{}, cn.hooks...), hooks...)
+}
+
+func (cn *conn) queryHooks() []ops.QueryHook {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.hooks
+}
+
+func (cn *conn) setMetrics(m ops.Metrics) {
+	if m == nil {
+		m = ops.NopMetrics
+	}
+	cn.Lock()
+	defer cn.Unlock()
+	cn.metrics = m
+}
+
+func (cn *conn) metricSink() ops.Metrics {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.metrics
+}
+
+func (cn *conn) setKeyProvider(k KeyProvider) {
+	cn.Lock()
+	defer cn.Unlock()
+	cn.keys = k
+}
+
+func (cn *conn) keyProvider() KeyProvider {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.keys
+}
+
+func (cn *conn) setTracerProvider(tp trace.TracerProvider) {
+	cn.Lock()
+	defer cn.Unlock()
+	cn.spans = newTracer(tp)
+}
+
+func (cn *conn) tracer() trace.Tracer {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.spans
+}
+
+func (cn *conn) log() ops.Logger {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.logger
+}
+
+// statement returns stmt as it may be logged, with the literal values
+// redacted unless debug query logging is on.
+func (cn *conn) statement(stmt string) string {
+	cn.RLock()
+	debug := cn.debugQueries
+	cn.RUnlock()
+	if debug {
+		return stmt
+	}
+	return redactCQL(stmt)
+}
+
+// logQuery logs a finished statement, at debug level when it succeeded and
+// at error level when it failed.
+func (cn *conn) logQuery(info ops.QueryInfo, d time.Duration, err error) {
+	logger := cn.log()
+	level := ops.LevelDebug
+	msg := "query executed"
+	if err != nil && !errors.Is(err, ops.ErrNotFound) {
+		level = ops.LevelError
+		msg = "query failed"
+	}
+	if !logger.Enabled(level) {
+		return
+	}
+	fields := []ops.Field{
+		ops.F(ops.FieldOperation, info.Operation),
+		ops.F(ops.FieldKeyspace, info.Keyspace),
+		ops.F(ops.FieldTable, info.Table),
+		ops.F(ops.FieldDuration, d),
+		ops.F(ops.FieldStatement, cn.statement(info.Statement)),
+	}
+	if err != nil {
+		fields = append(fields, ops.F(ops.FieldError, err))
+	}
+	logger.Log(level, msg, fields...)
+}
+
+// recordQuery reports a finished statement to the metrics sink.
+func (cn *conn) recordQuery(info ops.QueryInfo, result ops.QueryResult, err error) {
+	m := cn.metricSink()
+	labels := ops.Labels{
+		ops.LabelOperation: info.Operation,
+		ops.LabelKeyspace:  info.Keyspace,
+		ops.LabelTable:     info.Table,
+	}
+	m.Add(ops.MetricQueries, labels, 1)
+	m.Observe(ops.MetricQueryDuration, labels, result.Duration.Seconds())
+	if result.Rows > 0 {
+		m.Add(ops.MetricRows, labels, float64(result.Rows))
+	}
+	if result.Pages > 0 {
+		m.Add(ops.MetricPages, labels, float64(result.Pages))
+	}
+	if result.Retries > 0 {
+		m.Add(ops.MetricRetries, labels, float64(result.Retries))
+	}
+	if err != nil {
+		errLabels := ops.Labels{ops.LabelClass: ops.ErrorClass(err)}
+		for k, v := range labels {
+			errLabels[k] = v
+		}
+		m.Add(ops.MetricQueryErrors, errLabels, 1)
+	}
+}
@@ -2,7 +2,6 @@ package cassandradb
 
 import (
 	"errors"
-	"log"
 	"reflect"
 	"strconv"
 	"strings"
@@ -30,6 +29,8 @@ const obnConst = "order_by_num"
 const obConst = "order_by"
 const cktConst = "column_keytype"
 const cvtConst = "column_valuetype"
+const autoConst = "auto"
+const encConst = "encrypt"
 
 var primarykeys []string
 var clusteringkeys []string
@@ -65,6 +66,12 @@ type Entity struct {
 	orderbyField     string
 	orderbyFieldNum  int
 	indexKey         bool
+	// rules are the validation rules of the validate tag
+	rules []rule
+	// auto is the auto option, the value the table fills the column with
+	auto string
+	// encrypt is the encrypt option, the column holds the value encrypted
+	encrypt string
 }
 
 // parses each entry in the struct to build the characteristic of a given field
@@ -219,8 +226,7 @@ func CreateEntity(s interface{}) ([]Entity, error) {
 			column.clusteringKeyNum, _ = strconv.Atoi(val)
 		}
 
-		val, ok = m[ikConst]
-		log.Printf("%s : %t\n", val, ok)
+		_, ok = m[ikConst]
 		if ok {
 			column.indexKey = true
 		}
@@ -248,8 +254,68 @@ func CreateEntity(s interface{}) ([]Entity, error) {
 			column.orderbyFieldNum, _ = strconv.Atoi(val)
 		}
 
+		val, ok = m[autoConst]
+		if ok {
+			if err := parseAuto(v.Type().Field(i), val); err != nil {
+				return nil, err
+			}
+			column.auto = val
+		}
+
+		val, ok = m[encConst]
+		if ok {
+			enc, err := parseEncrypt(column, val)
+			if err != nil {
+				return nil, err
+			}
+			if enc != "" {
+				// the value of any type is stored sealed
+				column.encrypt = enc
+				column.columnType = "blob"
+				column.columnSubType, column.columnKeyType, column.columnValType = "", "", ""
+			}
+		}
+
+		rules, err := parseRules(v.Type().Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if column.primaryKey || column.clusteringKey {
+			rules = keyRules(v.Type().Field(i), rules)
+		}
+		column.rules = rules
+
 		entities = append(entities, column)
 
 	}
 	return entities, nil
 }
+
+// columnDefinition returns the cql type of the column of entity. It
+// reports false for an invalid collection type.
+func columnDefinition(entity Entity) (string, bool) {
+	if entity.columnType != "collection" {
+		return entity.columnType, true
+	}
+	switch entity.columnSubType {
+	case "map":
+		return "map<" + entity.columnKeyType + "," + entity.columnValType + ">", true
+	case "set":
+		return "set<" + entity.columnValType + ">", true
+	case "list":
+		return "list<" + entity.columnValType + ">", true
+	}
+	return "", false
+}
+
+// keyColumns returns the partition key columns of entities, or the
+// clustering columns when partition is false, in key order.
+func keyColumns(entities []Entity, partition bool) []string {
+	var cols []string
+	for _, entity := range entities {
+		if (partition && entity.primaryKey) || (!partition && entity.clusteringKey) {
+			cols = append(cols, entity.columnName)
+		}
+	}
+	return cols
+}
@@ -4,29 +4,37 @@
 package cassandradb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gocql/gocql"
-	"log"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const listKeyspacesStmt = `SELECT keyspace_name FROM system_schema.keyspaces`
+
 // Client implements the client interface to Cassandra.
 // serverList is the cassandra server cluster information.
 // keyspace is the keyspace to be used for this session.
 type Client struct {
 	sync.RWMutex
-	dbSession    *gocql.Session
+	conn         *conn
+	config       Config
 	serverList   string
 	keyspaceName string
 	keyspace     *KeySpace
 	clusterCfg   *gocql.ClusterConfig
 	// stats
 	reconnectCtr int64
+	// closed is set by Disconnect, a closed client is only reopened by Connect
+	closed bool
+	// connection supervision
+	health     healthState
+	supervisor chan struct{}
 }
 
 // NewClient returns an instance of Client after connecting
@@ -34,20 +42,43 @@ type Client struct {
 // serverList is the list of cassandra servers.
 // keyspace is the Cassandra keyspace used by this session.
 func NewClient(serverList string, keyspace string) (*Client, error) {
-	client := Client{serverList: serverList}
-	if keyspace != "" {
-		client.keyspaceName = keyspace
+	config := DefaultConfig
+	config.ServerList = serverList
+	config.KeySpace = keyspace
+	return NewClientWithConfig(config)
+}
+
+// NewClientWithConfig returns an instance of Client after connecting
+// to the cassandra servers in config. Settings missing from config are
+// taken from DefaultConfig. When config.Supervisor is enabled the
+// connection is monitored in the background, even if the first connect fails.
+func NewClientWithConfig(config Config) (*Client, error) {
+	config = config.withDefaults()
+	client := &Client{config: config, serverList: config.ServerList, conn: newConn(nil)}
+	client.conn.setLogger(config.Logger, config.DebugQueryLog)
+	client.conn.addHooks(config.QueryHooks...)
+	client.conn.setMetrics(config.Metrics)
+	client.conn.setTracerProvider(config.TracerProvider)
+	client.conn.setSlowQuery(config.SlowQuery)
+	client.conn.setLimits(config.Limits)
+	client.conn.setBreakers(config.Breaker)
+	client.conn.setKeyProvider(config.KeyProvider)
+	if config.KeySpace != "" {
+		client.keyspaceName = config.KeySpace
 	}
 
-	if err := client.Connect(); err != nil {
-		log.Printf("error connecting to Cassandra")
+	err := client.Connect()
+	if config.Supervisor.Enabled {
+		client.StartSupervisor()
+	}
+	if err != nil {
 		// return the initialized object rather than nil and let caller take care of reconnecting again
-		return &client, err
+		return client, err
 	}
 	/*if keyspace != "" {
 		client.keyspace = GetKeySpace(c.keyspaceName, c.dbSession)
 	} */
-	return &client, nil
+	return client, nil
 }
 
 // Connect connects or reconnects to Cassandra cluster using the info supplied
@@ -56,76 +87,191 @@ func (c *Client) Connect() error {
 	if c == nil {
 		return fmt.Errorf("nil cassdb client context")
 	}
-	c.clusterCfg = gocql.NewCluster(c.serverList)
-	c.clusterCfg.Consistency = gocql.Quorum
-	c.clusterCfg.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: 4}
-	c.clusterCfg.Consistency = gocql.One
-	var duration_Milliseconds time.Duration = 900 * time.Millisecond
-	c.clusterCfg.Timeout = duration_Milliseconds
-
+	if c.conn == nil {
+		c.conn = newConn(nil)
+	}
+	clusterCfg, err := newClusterConfig(c.config)
+	if err != nil {
+		return err
+	}
+	clusterCfg.ConnectObserver = connectObserver{c}
 	if c.keyspace != nil {
-		c.clusterCfg.Keyspace = c.keyspaceName
+		clusterCfg.Keyspace = c.keyspaceName
 	}
-	var errS error
-	c.dbSession, errS = c.clusterCfg.CreateSession()
+	// the supervisor reads the config under the lock to reopen the session
+	c.Lock()
+	c.closed = false
+	c.clusterCfg = clusterCfg
+	c.Unlock()
+
+	dbSession, errS := clusterCfg.CreateSession()
 	if errS != nil {
-		log.Printf("error creating Cassandra session to cluster: %v :: %v", c.serverList, errS)
-		c.dbSession = nil
+		c.conn.log().Log(ops.LevelError, "error creating Cassandra session",
+			ops.F("servers", c.serverList), ops.F(ops.FieldError, errS))
+		c.conn.setSession(nil)
+		c.changeState(StateDisconnected, errS, true)
 		return errS
 	}
+	c.conn.setSession(dbSession)
 
-	if c.keyspace != nil {
-		c.keyspace.dbSession = c.dbSession
-	} else {
-		c.keyspace = GetKeySpace(c.keyspaceName, c.dbSession)
-		c.keyspace.dbSession = c.dbSession
+	if c.keyspace == nil {
+		c.keyspace = newKeySpace(c.keyspaceName, c.conn)
 	}
+	c.changeState(StateConnected, nil, true)
 
 	return nil
 }
 
-// Disconnect removes the connectivity to Cassandra cluster
+// Disconnect removes the connectivity to Cassandra cluster and stops
+// the connection supervisor.
 func (c *Client) Disconnect() error {
-	if c.Isconnected() {
-		c.dbSession.Close()
-	}
+	// a reconnect in flight holds the lock, it either completes before and
+	// its session is closed below, or it sees the flag and gives up
+	c.Lock()
+	c.closed = true
+	c.Unlock()
+	c.StopSupervisor()
+	c.closeSession()
+	c.setState(StateClosed, nil)
 	return nil
 }
 
+func (c *Client) closeSession() {
+	if s := c.conn.setSession(nil); s != nil {
+		s.Close()
+	}
+}
+
+// Isconnected reports whether the client has an open session that
+// has not been marked down by the connection supervisor.
 func (c *Client) Isconnected() bool {
-	return c.dbSession != nil
+	s := c.conn.session()
+	if s == nil || s.Closed() {
+		return false
+	}
+	return c.health.current() != StateDisconnected
 }
 
+// ReConnect closes the current session and opens a new one. Keyspaces and
+// tables obtained from this client use the new session.
+// A client closed by Disconnect is not reopened, use Connect.
 func (c *Client) ReConnect() error {
-	var errS error
+	err := c.reopenSession()
+	if errors.Is(err, errClientClosed) || errors.Is(err, errNotConfigured) {
+		return err
+	}
+	if err != nil {
+		c.setState(StateDisconnected, err)
+		return err
+	}
+	c.setState(StateConnected, nil)
+	return nil
+}
+
+var (
+	errClientClosed  = errors.New("client is closed, call Connect first")
+	errNotConfigured = errors.New("client was never configured, call Connect first")
+)
+
+// reopenSession is ReConnect without the state change, for the supervisor
+// to stay reconnecting across attempts.
+func (c *Client) reopenSession() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.clusterCfg == nil {
+		return errNotConfigured
+	}
+	if c.closed {
+		return errClientClosed
+	}
 
 	atomic.AddInt64(&c.reconnectCtr, 1)
+	c.conn.metricSink().Add(ops.MetricReconnects, nil, 1)
 
-	c.Disconnect() // ignore error
+	c.closeSession()
 
-	c.dbSession, errS = c.clusterCfg.CreateSession()
+	dbSession, errS := c.clusterCfg.CreateSession()
 	if errS != nil {
-		log.Printf("error creating Cassandra session to cluster: ", c.serverList,
-			" :: ", errS)
-		c.dbSession = nil
+		c.conn.log().Log(ops.LevelError, "error recreating Cassandra session",
+			ops.F("servers", c.serverList), ops.F(ops.FieldError, errS))
 		return errS
 	}
-
+	c.conn.setSession(dbSession)
+	if c.keyspace == nil {
+		c.keyspace = newKeySpace(c.keyspaceName, c.conn)
+	}
 	return nil
 }
 
-func (c *Client) GetDB() (ops.Database, error) {
+// AddQueryHook registers hooks that are called around every statement run by
+// the client and by the keyspaces and tables obtained from it.
+func (c *Client) AddQueryHook(hooks ...ops.QueryHook) {
+	c.conn.addHooks(hooks...)
+}
+
+// SetMetrics replaces the metrics sink of the client and of every keyspace
+// and table obtained from it.
+func (c *Client) SetMetrics(m ops.Metrics) {
+	c.conn.setMetrics(m)
+}
 
-	if c.dbSession == nil {
-		return nil, errors.New("No connections found. First connect to database server before calling this method")
+// SetTracerProvider replaces the tracer provider used for the spans of the
+// client and of every keyspace and table obtained from it.
+func (c *Client) SetTracerProvider(tp trace.TracerProvider) {
+	c.conn.setTracerProvider(tp)
+}
+
+// SetSlowQueryLog replaces the slow query settings of the client and of
+// every keyspace and table obtained from it.
+func (c *Client) SetSlowQueryLog(cfg SlowQueryConfig) {
+	c.conn.setSlowQuery(cfg)
+}
+
+// SetLimits replaces the client side budgets of the client and of every
+// keyspace and table obtained from it.
+func (c *Client) SetLimits(cfg LimitsConfig) {
+	c.conn.setLimits(cfg)
+}
+
+// SetBreakers replaces the circuit breakers of the client and of every
+// keyspace and table obtained from it.
+func (c *Client) SetBreakers(cfg BreakerConfig) {
+	c.conn.setBreakers(cfg)
+}
+
+// BreakerState returns the state of the circuit breaker of an operation
+// class, closed when the class has no breaker.
+func (c *Client) BreakerState(class string) ops.BreakerState {
+	if b := c.conn.breaker(class); b != nil {
+		return b.current()
 	}
+	return ops.BreakerClosed
+}
 
-	if c.keyspace == nil {
-		return nil, errors.New("No database found. First set database name before getting database")
+// SetLogger replaces the logger of the client and of every keyspace and
+// table obtained from it. When debugQueries is set statements are logged
+// with their values, otherwise the values are redacted.
+func (c *Client) SetLogger(logger ops.Logger, debugQueries bool) {
+	c.conn.setLogger(logger, debugQueries)
+}
+
+// ReconnectCount returns the number of times a configured client reopened
+// its session, either through ReConnect or by the connection supervisor.
+func (c *Client) ReconnectCount() int64 {
+	return atomic.LoadInt64(&c.reconnectCtr)
+}
+
+func (c *Client) GetDB() (ops.Database, error) {
+
+	if c.conn.session() == nil {
+		return nil, ops.NewOpError(ops.OpGetDB, c.keyspaceName, "", ops.ErrUnavailable,
+			"No connections found. First connect to database server before calling this method")
 	}
 
-	if c.keyspace.Name == "" {
-		return nil, errors.New("No database found. First set database name before getting database")
+	if c.keyspace == nil || c.keyspace.Name == "" {
+		return nil, ops.NewOpError(ops.OpGetDB, c.keyspaceName, "", ops.ErrInvalidQuery,
+			"No database found. First set database name before getting database")
 	}
 
 	return c.keyspace, nil
@@ -136,78 +282,80 @@ func (c *Client) SetDBName(name string) error {
 		return nil
 	}
 
-	c.dbSession.Close()
+	c.Lock()
+	defer c.Unlock()
+	c.closeSession()
 	c.clusterCfg.Keyspace = name
-	var errS error
-	c.dbSession, errS = c.clusterCfg.CreateSession()
-	return errS
+	dbSession, errS := c.clusterCfg.CreateSession()
+	if errS != nil {
+		c.setState(StateDisconnected, errS)
+		return errS
+	}
+	c.conn.setSession(dbSession)
+	return nil
 }
 
 // CreateDB creates a keyspace in Cassandra given the name of the
 // keyspace and the gocql session
 func (c *Client) CreateDB(name string) error {
 	// already exists
-	if ok, err := c.DoesDBExist(name); err == nil {
-		if ok {
-			return nil
-		}
-	}
-
-	ksStr := fmt.Sprintf("CREATE KEYSPACE %s WITH REPLICATION = { 'class' : "+
-		" 'SimpleStrategy', 'replication_factor' : 1 };", name)
-	cassQuery, err := CreateQuery(c.dbSession, ksStr)
+	ok, err := c.DoesDBExist(name)
 	if err != nil {
 		return err
 	}
-	if err = ExecQuery(cassQuery); err != nil {
-		log.Printf("could not create keyspace: %s :: %v", c.keyspace, err)
-		return err
+	if ok {
+		return nil
 	}
-	return nil
+
+	ksStr := fmt.Sprintf("CREATE KEYSPACE %s WITH REPLICATION = { 'class' : "+
+		" 'SimpleStrategy', 'replication_factor' : 1 };", name)
+	return c.conn.exec(context.Background(), ops.QueryInfo{Operation: ops.OpCreateDB, Keyspace: name, Statement: ksStr})
 }
 
 // DropDB is used to drop the cassandra keyspace
 func (c *Client) DropDB(name string) error {
 	dropStr := fmt.Sprintf("DROP KEYSPACE %s", name)
-	cassQuery, err := CreateQuery(c.dbSession, dropStr)
-	if err != nil {
-		return err
-	}
-	if err = ExecQuery(cassQuery); err != nil {
-		log.Printf("could not drop keyspace: %s :: %v", name, err)
-	}
-	log.Printf("dropped keyspace: %s", name)
-	return nil
+	return c.conn.exec(context.Background(), ops.QueryInfo{Operation: ops.OpDropDB, Keyspace: name, Statement: dropStr})
 }
 
 //  Returns a list of databases in the Cassandra server list this client points to
 func (c *Client) ListDBs() ([]string, error) {
-	if c.dbSession == nil {
-		return nil, errors.New("No valid session found")
+	if c.conn.session() == nil {
+		return nil, ops.NewOpError(ops.OpListDBs, "", "", ops.ErrUnavailable, "No valid session found")
 	}
 	var name string
 	keyspaces := []string{}
-	iter := c.dbSession.Query(`SELECT keyspace_name FROM ` +
-		`system_schema.keyspaces`).Iter()
-	for iter.Scan(&name) {
+	info := ops.QueryInfo{Operation: ops.OpListDBs, Statement: listKeyspacesStmt}
+	err := c.conn.iter(context.Background(), info, nil, func(iter *gocql.Iter) bool {
+		if !iter.Scan(&name) {
+			return false
+		}
 		keyspaces = append(keyspaces, name)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	return keyspaces, nil
 }
 
 //  Checks to see if a given cassandra keyspace exists.
 func (c *Client) DoesDBExist(name string) (bool, error) {
-	if c.dbSession == nil {
-		return false, errors.New("No valid session found")
+	if c.conn.session() == nil {
+		return false, ops.NewOpError(ops.OpDBExists, name, "", ops.ErrUnavailable, "No valid session found")
 	}
 	var keyspace string
-	iter := c.dbSession.Query(`SELECT keyspace_name FROM ` +
-		`system_schema.keyspaces`).Iter()
-	for iter.Scan(&keyspace) {
-		if name == keyspace {
-			log.Printf("keySpace %s exists", name)
-			return true, nil
+	found := false
+	info := ops.QueryInfo{Operation: ops.OpDBExists, Keyspace: name, Statement: listKeyspacesStmt}
+	err := c.conn.iter(context.Background(), info, nil, func(iter *gocql.Iter) bool {
+		if !iter.Scan(&keyspace) {
+			return false
 		}
+		found = name == keyspace
+		return !found
+	})
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	return found, nil
 }
@@ -0,0 +1,273 @@
+package cassandradb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+// Values of the encrypt option of the cql tag. Encrypted columns are blob
+// columns holding the value sealed with AES-GCM under a key of the
+// KeyProvider of the client, the column name is bound to the value as
+// additional data. Strings and blobs are encrypted as they are, other
+// values JSON encoded.
+//
+// encrypt=true uses a random nonce, equal values are stored differently.
+// encrypt=deterministic derives the nonce from the value, so that equal
+// values written with the same key are stored the same and the column can
+// be looked up with = in a where clause, at the cost of revealing which
+// rows hold equal values. A lookup encrypts with the current key, it does
+// not find values written with a key rotated out since.
+//
+// Key columns and counters cannot be encrypted.
+const (
+	encryptRandom        = "true"
+	encryptDeterministic = "deterministic"
+)
+
+// KeyProvider supplies the AES keys of encrypted columns, of 16, 24 or 32
+// bytes. Values are encrypted with the current key and record its id, a
+// key rotated out must still be returned by Key for the values written
+// with it to be read. Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// CurrentKey returns the key new values are encrypted with, and its id
+	// of at most 255 bytes.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with id.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding its keys in memory. Rotating keys
+// means adding a key to Keys and making it Current.
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// encryptedFormat is the first byte of an encrypted value, followed by the
+// length of the key id, the key id, the nonce and the sealed value.
+const encryptedFormat = 1
+
+var errMalformedCiphertext = errors.New("malformed encrypted value")
+
+// parseEncrypt checks the encrypt option of column.
+func parseEncrypt(column Entity, value string) (string, error) {
+	switch value {
+	case "false":
+		return "", nil
+	case encryptRandom, encryptDeterministic:
+	default:
+		return "", CassandraDBError{time.Now(), "invalid encrypt option on " + column.fieldName, value}
+	}
+	if column.primaryKey || column.clusteringKey || column.columnType == "counter" {
+		return "", CassandraDBError{time.Now(), "cannot encrypt key or counter column", column.columnName}
+	}
+	return value, nil
+}
+
+// keyProvider returns the key provider of the table for op.
+func (t *Table) keyProvider(op string) (KeyProvider, error) {
+	keys := t.conn.keyProvider()
+	if keys == nil {
+		return nil, t.invalidQuery(op, "no key provider for encrypted columns")
+	}
+	return keys, nil
+}
+
+// encryptValue returns v encrypted for the column of entity.
+func (t *Table) encryptValue(op string, entity Entity, v interface{}) ([]byte, error) {
+	keys, err := t.keyProvider(op)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := plaintext(v)
+	if err != nil {
+		return nil, t.invalidQuery(op, "column %s: %v", entity.columnName, err)
+	}
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, wrapError(t.queryInfo(op, ""), err)
+	}
+	sealed, err := sealValue(entity, id, key, plain)
+	if err != nil {
+		return nil, wrapError(t.queryInfo(op, ""), err)
+	}
+	return sealed, nil
+}
+
+// decryptValue sets field to the value encrypted in sealed. A null column
+// leaves the field zero.
+func (t *Table) decryptValue(op string, entity Entity, sealed []byte, field reflect.Value) error {
+	if len(sealed) == 0 {
+		return nil
+	}
+	keys, err := t.keyProvider(op)
+	if err != nil {
+		return err
+	}
+	plain, err := openValue(entity, keys, sealed)
+	if err == nil {
+		err = setPlaintext(field, plain)
+	}
+	if err != nil {
+		return ops.NewOpError(op, t.KeySpace, t.Name, ops.ErrSchemaMismatch, "column %s: %v", entity.columnName, err)
+	}
+	return nil
+}
+
+func sealValue(entity Entity, id string, key, plain []byte) ([]byte, error) {
+	if len(id) > 255 {
+		return nil, fmt.Errorf("encryption key id too long: %d bytes", len(id))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if entity.encrypt == encryptDeterministic {
+		mac := hmac.New(sha256.New, nonceKey(key))
+		mac.Write([]byte(entity.columnName))
+		mac.Write([]byte{0})
+		mac.Write(plain)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 2+len(id)+len(nonce)+len(plain)+aead.Overhead())
+	out = append(out, encryptedFormat, byte(len(id)))
+	out = append(out, id...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, []byte(entity.columnName)), nil
+}
+
+func openValue(entity Entity, keys KeyProvider, sealed []byte) ([]byte, error) {
+	if len(sealed) < 2 || sealed[0] != encryptedFormat || len(sealed) < 2+int(sealed[1]) {
+		return nil, errMalformedCiphertext
+	}
+	id := string(sealed[2 : 2+int(sealed[1])])
+	rest := sealed[2+int(sealed[1]):]
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errMalformedCiphertext
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(entity.columnName))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonceKey derives the key of the deterministic nonces from key, so that
+// the encryption key is not also used as a MAC key.
+func nonceKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("goava deterministic nonce"))
+	return mac.Sum(nil)
+}
+
+// plaintext returns the bytes encrypted for v.
+func plaintext(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+		return json.Marshal(nil)
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), nil
+	}
+	return json.Marshal(v)
+}
+
+// setPlaintext sets field to the value decrypted from plain, the reverse
+// of plaintext.
+func setPlaintext(field reflect.Value, plain []byte) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(string(plain))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes(plain)
+	default:
+		return json.Unmarshal(plain, field.Addr().Interface())
+	}
+	return nil
+}
+
+// encryptWhere returns whereClause with the values compared to encrypted
+// columns encrypted. Only deterministic columns can be compared, with =.
+func (t *Table) encryptWhere(op string, whereClause []whc.WhereClauseType) ([]whc.WhereClauseType, error) {
+	var out []whc.WhereClauseType
+	for i, wc := range whereClause {
+		entity, ok := t.entity(wc.ColumnName)
+		if !ok || entity.encrypt == "" {
+			continue
+		}
+		if entity.encrypt != encryptDeterministic || wc.RelationType != "=" {
+			return nil, t.invalidQuery(op, "encrypted column can only be compared with = when deterministic :: %s", wc.ColumnName)
+		}
+		sealed, err := t.encryptValue(op, entity, wc.ColumnValue)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = append([]whc.WhereClauseType{}, whereClause...)
+		}
+		out[i].ColumnValue = sealed
+	}
+	if out == nil {
+		return whereClause, nil
+	}
+	return out, nil
+}
+
+// decryptTargets decrypts the encrypted columns scanned into targets, see
+// scanTargets, into row.
+func (t *Table) decryptTargets(op string, row reflect.Value, cols []projectedColumn, targets []interface{}) error {
+	for i, col := range cols {
+		if col.kind != plainColumn || col.entity.encrypt == "" {
+			continue
+		}
+		sealed, ok := targets[i].(*[]byte)
+		if !ok {
+			continue
+		}
+		if err := t.decryptValue(op, col.entity, *sealed, row.FieldByName(col.entity.fieldName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package cassandradb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secretUser struct {
+	ID    string `cql:"column_name=id,primary_key=0"`
+	Email string `cql:"column_name=email,index_key=true,encrypt=deterministic"`
+	SSN   string `cql:"column_name=ssn,encrypt=true"`
+	Born  int    `cql:"column_name=born,encrypt=true"`
+	Name  string `cql:"column_name=name"`
+}
+
+var testKeys = StaticKeys{Current: "k1", Keys: map[string][]byte{
+	"k1": bytes.Repeat([]byte{1}, 32),
+	"k2": bytes.Repeat([]byte{2}, 16),
+}}
+
+func newSecretTable(t *testing.T) (*Table, *[]string) {
+	tbl, stmts := newRecordingTable(t, secretUser{})
+	tbl.conn.setKeyProvider(testKeys)
+	return tbl, stmts
+}
+
+// storedRow returns the row as MapScan returns it once inserted.
+func storedRow(t *testing.T, tbl *Table, row secretUser) map[string]interface{} {
+	_, values, err := tbl.insertStatement(row, false)
+	require.NoError(t, err)
+	stored := make(map[string]interface{})
+	for i, entity := range tbl.entities {
+		stored[entity.columnName] = values[i]
+	}
+	return stored
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	tbl, _ := newSecretTable(t)
+	for _, entity := range tbl.entities {
+		if entity.encrypt != "" {
+			assert.Equal(t, "blob", entity.columnType)
+		}
+	}
+
+	user := secretUser{ID: "u1", Email: "amy@example.com", SSN: "123-45-6789", Born: 1990, Name: "amy"}
+	stored := storedRow(t, tbl, user)
+	assert.Equal(t, "amy", stored["name"])
+	for _, col := range []string{"email", "ssn", "born"} {
+		sealed, ok := stored[col].([]byte)
+		require.True(t, ok, col)
+		assert.Equal(t, "k1", string(sealed[2:4]))
+		assert.NotContains(t, string(sealed), "amy@example.com")
+	}
+
+	row, err := tbl.rowFromMap(ops.OpList, stored)
+	require.NoError(t, err)
+	assert.Equal(t, user, row.Interface())
+
+	// rows read with Scan decrypt the blobs scanned for encrypted columns
+	cols, err := tbl.projection(ops.OpRead)
+	require.NoError(t, err)
+	read := secretUser{}
+	targets := tbl.scanTargets(reflect.ValueOf(&read).Elem(), cols)
+	for i, col := range cols {
+		if b, ok := targets[i].(*[]byte); ok {
+			*b = stored[col.entity.columnName].([]byte)
+		}
+	}
+	require.NoError(t, tbl.decryptTargets(ops.OpRead, reflect.ValueOf(&read).Elem(), cols, targets))
+	assert.Equal(t, user.SSN, read.SSN)
+	assert.Equal(t, user.Born, read.Born)
+
+	// a null column leaves the field zero
+	stored["ssn"] = []byte(nil)
+	row, err = tbl.rowFromMap(ops.OpList, stored)
+	require.NoError(t, err)
+	assert.Equal(t, "", row.Interface().(secretUser).SSN)
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	tbl, _ := newSecretTable(t)
+	user := secretUser{ID: "u1", Email: "amy@example.com", SSN: "1"}
+	old := storedRow(t, tbl, user)
+
+	rotated := StaticKeys{Current: "k2", Keys: testKeys.Keys}
+	tbl.conn.setKeyProvider(rotated)
+	current := storedRow(t, tbl, user)
+	assert.Equal(t, "k2", string(current["ssn"].([]byte)[2:4]))
+
+	for _, stored := range []map[string]interface{}{old, current} {
+		row, err := tbl.rowFromMap(ops.OpList, stored)
+		require.NoError(t, err)
+		assert.Equal(t, user, row.Interface())
+	}
+
+	// values of a key the provider no longer has cannot be read
+	tbl.conn.setKeyProvider(StaticKeys{Current: "k2", Keys: map[string][]byte{"k2": testKeys.Keys["k2"]}})
+	_, err := tbl.rowFromMap(ops.OpList, old)
+	assert.ErrorIs(t, err, ops.ErrSchemaMismatch)
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	tbl, _ := newSecretTable(t)
+	a := storedRow(t, tbl, secretUser{ID: "a", Email: "amy@example.com", SSN: "1"})
+	b := storedRow(t, tbl, secretUser{ID: "b", Email: "amy@example.com", SSN: "1"})
+	assert.Equal(t, a["email"], b["email"])
+	assert.NotEqual(t, a["ssn"], b["ssn"])
+
+	// the column name is bound to the value
+	other := storedRow(t, tbl, secretUser{ID: "c", Email: "1", SSN: "1"})
+	assert.NotEqual(t, other["email"], a["ssn"])
+}
+
+func TestEncryptTampered(t *testing.T) {
+	tbl, _ := newSecretTable(t)
+	stored := storedRow(t, tbl, secretUser{ID: "a", Email: "amy@example.com", SSN: "1"})
+
+	sealed := append([]byte{}, stored["ssn"].([]byte)...)
+	sealed[len(sealed)-1] ^= 1
+	_, err := tbl.rowFromMap(ops.OpList, map[string]interface{}{"ssn": sealed})
+	assert.ErrorIs(t, err, ops.ErrSchemaMismatch)
+
+	// a value copied to another encrypted column
+	_, err = tbl.rowFromMap(ops.OpList, map[string]interface{}{"email": stored["ssn"]})
+	assert.ErrorIs(t, err, ops.ErrSchemaMismatch)
+
+	_, err = tbl.rowFromMap(ops.OpList, map[string]interface{}{"ssn": []byte{9, 9}})
+	assert.ErrorIs(t, err, ops.ErrSchemaMismatch)
+}
+
+func TestEncryptWhere(t *testing.T) {
+	tbl, stmts := newSecretTable(t)
+	email := []whc.WhereClauseType{{ColumnName: "email", RelationType: "=", ColumnValue: "amy@example.com"}}
+	sealed, err := tbl.encryptWhere(ops.OpList, email)
+	require.NoError(t, err)
+	assert.Equal(t, storedRow(t, tbl, secretUser{ID: "a", Email: "amy@example.com"})["email"], sealed[0].ColumnValue)
+	assert.Equal(t, "amy@example.com", email[0].ColumnValue)
+
+	_, err = tbl.List(email, nil, nil, 0, "")
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	_, err = tbl.Read(email, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.ErrorIs(t, tbl.Delete(nil, email), ops.ErrUnavailable)
+	fields := map[string]interface{}{"ssn": "2"}
+	assert.ErrorIs(t, tbl.UpdateFields(fields, nil, []whc.WhereClauseType{{ColumnName: "id", RelationType: "=", ColumnValue: "a"}}), ops.ErrUnavailable)
+	require.Len(t, *stmts, 4)
+	assert.True(t, strings.HasPrefix((*stmts)[0], "SELECT id, email, ssn, born, name FROM ks.views WHERE email = 0x01026b31"), (*stmts)[0])
+	assert.Equal(t, "SELECT id, email, ssn, born, name FROM ks.views WHERE email = ?;", (*stmts)[1])
+	assert.Equal(t, "DELETE FROM ks.views WHERE email = ?;", (*stmts)[2])
+	assert.Equal(t, "UPDATE ks.views SET ssn = ? WHERE id = ?;", (*stmts)[3])
+
+	for _, where := range [][]whc.WhereClauseType{
+		{{ColumnName: "ssn", RelationType: "=", ColumnValue: "1"}},
+		{{ColumnName: "email", RelationType: ">", ColumnValue: "a"}},
+		{{ColumnName: "email", RelationType: "in", ColumnValue: []string{"a"}}},
+	} {
+		_, err = tbl.List(where, nil, nil, 0, "")
+		assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+		assert.ErrorIs(t, tbl.Delete(nil, where), ops.ErrInvalidQuery)
+	}
+	_, err = tbl.Aggregate([]ops.Aggregation{{Func: ops.AggMax, Column: "born"}}, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+	assert.Len(t, *stmts, 4)
+}
+
+func TestEncryptWithoutKeyProvider(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, secretUser{})
+	assert.ErrorIs(t, tbl.Insert(secretUser{ID: "a", Email: "e"}), ops.ErrInvalidQuery)
+	_, err := tbl.rowFromMap(ops.OpList, map[string]interface{}{"ssn": []byte{1}})
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+	assert.Empty(t, *stmts)
+}
+
+func TestEncryptTags(t *testing.T) {
+	for _, model := range []interface{}{
+		struct {
+			ID string `cql:"column_name=id,primary_key=0,encrypt=true"`
+		}{},
+		struct {
+			ID string `cql:"column_name=id,primary_key=0"`
+			At string `cql:"column_name=at,clustering_key=0,encrypt=deterministic"`
+		}{},
+		struct {
+			ID   string `cql:"column_name=id,primary_key=0"`
+			Hits int64  `cql:"column_name=hits,column_type=counter,encrypt=true"`
+		}{},
+		struct {
+			ID   string `cql:"column_name=id,primary_key=0"`
+			Note string `cql:"column_name=note,encrypt=aes"`
+		}{},
+	} {
+		_, err := CreateEntity(model)
+		assert.Error(t, err, "%T", model)
+	}
+
+	entities, err := CreateEntity(struct {
+		ID   string   `cql:"column_name=id,primary_key=0"`
+		Tags []string `cql:"column_name=tags,column_type=collection,column_subtype=set,column_valuetype=text,encrypt=false"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, "", entities[1].encrypt)
+	assert.Equal(t, "collection", entities[1].columnType)
+}
+
+func TestEncryptParquetValue(t *testing.T) {
+	tbl, _ := newSecretTable(t)
+	user := reflect.ValueOf(secretUser{SSN: "1", Born: 1990})
+	for _, entity := range tbl.entities {
+		v, err := parquetValue(entity, user.FieldByName(entity.fieldName))
+		require.NoError(t, err)
+		assert.Equal(t, parquetGoType(entity), v.Type(), entity.columnName)
+	}
+}
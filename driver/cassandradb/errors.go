@@ -0,0 +1,98 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// errorKind maps a gocql error to one of the ops error classes.
+// It returns nil when the error does not fit any class.
+func errorKind(err error) error {
+	var dbErr *ops.DatabaseError
+	if errors.As(err, &dbErr) {
+		return dbErr
+	}
+	switch {
+	case errors.Is(err, gocql.ErrNotFound):
+		return ops.ErrNotFound
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, gocql.ErrTimeoutNoResponse),
+		errors.Is(err, gocql.ErrTooManyTimeouts):
+		return ops.ErrTimeout
+	case errors.Is(err, gocql.ErrNoConnections),
+		errors.Is(err, gocql.ErrNoConnectionsStarted),
+		errors.Is(err, gocql.ErrConnectionClosed),
+		errors.Is(err, gocql.ErrSessionClosed),
+		errors.Is(err, gocql.ErrUnavailable):
+		return ops.ErrUnavailable
+	case errors.Is(err, gocql.ErrNoStreams):
+		return ops.ErrOverloaded
+	case errors.Is(err, gocql.ErrQueryArgLength),
+		errors.Is(err, gocql.ErrNoKeyspace),
+		errors.Is(err, gocql.ErrKeyspaceDoesNotExist):
+		return ops.ErrInvalidQuery
+	}
+
+	var marshalErr gocql.MarshalError
+	var unmarshalErr gocql.UnmarshalError
+	if errors.As(err, &marshalErr) || errors.As(err, &unmarshalErr) {
+		return ops.ErrSchemaMismatch
+	}
+
+	var reqErr gocql.RequestError
+	if !errors.As(err, &reqErr) {
+		return nil
+	}
+	switch reqErr.Code() {
+	case gocql.ErrCodeReadTimeout, gocql.ErrCodeWriteTimeout, gocql.ErrCodeCASWriteUnknown:
+		return ops.ErrTimeout
+	case gocql.ErrCodeUnavailable, gocql.ErrCodeBootstrapping, gocql.ErrCodeReadFailure,
+		gocql.ErrCodeWriteFailure:
+		return ops.ErrUnavailable
+	case gocql.ErrCodeOverloaded, gocql.ErrCodeTruncate:
+		return ops.ErrOverloaded
+	case gocql.ErrCodeAlreadyExists:
+		return ops.ErrSchemaMismatch
+	case gocql.ErrCodeInvalid:
+		msg := strings.ToLower(reqErr.Message())
+		if strings.Contains(msg, "undefined column") || strings.Contains(msg, "unconfigured table") ||
+			strings.Contains(msg, "unknown identifier") {
+			return ops.ErrSchemaMismatch
+		}
+		return ops.ErrInvalidQuery
+	case gocql.ErrCodeSyntax, gocql.ErrCodeUnauthorized, gocql.ErrCodeConfig,
+		gocql.ErrCodeUnprepared, gocql.ErrCodeFunctionFailure:
+		return ops.ErrInvalidQuery
+	}
+	return nil
+}
+
+// wrapError wraps a driver error into an *ops.OpError carrying the
+// operation and table of the statement. Errors that are already wrapped
+// are returned unchanged.
+func wrapError(info ops.QueryInfo, err error) error {
+	if err == nil {
+		return nil
+	}
+	var opErr *ops.OpError
+	if errors.As(err, &opErr) {
+		return err
+	}
+	return &ops.OpError{
+		Op:       info.Operation,
+		Keyspace: info.Keyspace,
+		Table:    info.Table,
+		Kind:     errorKind(err),
+		Err:      err,
+	}
+}
+
+// invalidQuery returns an ErrInvalidQuery error for a request rejected
+// before it reached the cluster.
+func (t *Table) invalidQuery(op string, format string, args ...interface{}) error {
+	return ops.NewOpError(op, t.KeySpace, t.Name, ops.ErrInvalidQuery, format, args...)
+}
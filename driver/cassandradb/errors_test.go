@@ -0,0 +1,131 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestError is a gocql.RequestError with the code of a server error
+// frame, the frames of gocql cannot be built outside the package.
+type requestError struct {
+	code int
+	msg  string
+}
+
+func (e requestError) Code() int       { return e.code }
+func (e requestError) Message() string { return e.msg }
+func (e requestError) Error() string   { return fmt.Sprintf("code %#x: %s", e.code, e.msg) }
+
+var _ gocql.RequestError = requestError{}
+
+func TestErrorKind(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"read timeout", requestError{gocql.ErrCodeReadTimeout, "read timeout"}, ops.ErrTimeout},
+		{"write timeout", requestError{gocql.ErrCodeWriteTimeout, "write timeout"}, ops.ErrTimeout},
+		{"cas write unknown", requestError{gocql.ErrCodeCASWriteUnknown, "cas"}, ops.ErrTimeout},
+		{"client timeout", gocql.ErrTimeoutNoResponse, ops.ErrTimeout},
+		{"deadline", context.DeadlineExceeded, ops.ErrTimeout},
+		{"unavailable", requestError{gocql.ErrCodeUnavailable, "not enough replicas"}, ops.ErrUnavailable},
+		{"bootstrapping", requestError{gocql.ErrCodeBootstrapping, "bootstrapping"}, ops.ErrUnavailable},
+		{"no connections", gocql.ErrNoConnections, ops.ErrUnavailable},
+		{"session closed", gocql.ErrSessionClosed, ops.ErrUnavailable},
+		{"overloaded", requestError{gocql.ErrCodeOverloaded, "overloaded"}, ops.ErrOverloaded},
+		{"no streams", gocql.ErrNoStreams, ops.ErrOverloaded},
+		{"not found", gocql.ErrNotFound, ops.ErrNotFound},
+		{"syntax", requestError{gocql.ErrCodeSyntax, "line 1:7 no viable alternative"}, ops.ErrInvalidQuery},
+		{"invalid", requestError{gocql.ErrCodeInvalid, "Invalid STRING constant"}, ops.ErrInvalidQuery},
+		{"unauthorized", requestError{gocql.ErrCodeUnauthorized, "no permission"}, ops.ErrInvalidQuery},
+		{"undefined column", requestError{gocql.ErrCodeInvalid, "Undefined column name x"}, ops.ErrSchemaMismatch},
+		{"unconfigured table", requestError{gocql.ErrCodeInvalid, "unconfigured table t"}, ops.ErrSchemaMismatch},
+		{"already exists", requestError{gocql.ErrCodeAlreadyExists, "table exists"}, ops.ErrSchemaMismatch},
+		{"unmarshal", gocql.UnmarshalError("can not unmarshal"), ops.ErrSchemaMismatch},
+		{"lwt not applied", ops.ErrConflict, ops.ErrConflict},
+		{"wrapped", fmt.Errorf("query: %w", gocql.ErrNotFound), ops.ErrNotFound},
+		{"unknown", errors.New("boom"), nil},
+	} {
+		assert.Equal(t, tc.kind, errorKind(tc.err), tc.name)
+
+		info := ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "t"}
+		err := wrapError(info, tc.err)
+		var opErr *ops.OpError
+		require.True(t, errors.As(err, &opErr), tc.name)
+		assert.Equal(t, ops.OpRead, opErr.Op, tc.name)
+		assert.Equal(t, "ks", opErr.Keyspace, tc.name)
+		assert.Equal(t, "t", opErr.Table, tc.name)
+		assert.ErrorIs(t, err, tc.err, tc.name)
+		if tc.kind != nil {
+			assert.ErrorIs(t, err, tc.kind, tc.name)
+		}
+
+		// the driver error stays reachable
+		var reqErr gocql.RequestError
+		if errors.As(tc.err, &reqErr) {
+			var got gocql.RequestError
+			require.True(t, errors.As(err, &got), tc.name)
+			assert.Equal(t, reqErr.Code(), got.Code(), tc.name)
+		}
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	assert.NoError(t, wrapError(ops.QueryInfo{}, nil))
+
+	// errors already wrapped keep their context
+	inner := fmt.Errorf("x: %w", ops.NewOpError(ops.OpInsert, "ks", "a", ops.ErrInvalidQuery, "bad"))
+	assert.Same(t, inner, wrapError(ops.QueryInfo{Operation: ops.OpRead, Table: "b"}, inner))
+
+	err := wrapError(ops.QueryInfo{Operation: ops.OpInsert, Keyspace: "ks", Table: "t"}, ops.ErrConflict)
+	assert.Equal(t, "insert ks.t: conflict, condition not applied", err.Error())
+	assert.False(t, errors.Is(err, ops.ErrTimeout))
+}
+
+func TestGetDBErrors(t *testing.T) {
+	c, _ := newTestClient()
+	_, err := c.GetDB()
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+
+	// connected without a keyspace
+	c.conn.setSession(&gocql.Session{})
+	_, err = c.GetDB()
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+	c.keyspace = &KeySpace{}
+	_, err = c.GetDB()
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestTableDDLErrors(t *testing.T) {
+	ks := newKeySpace("ks", newConn(nil))
+	var stmts []string
+	ks.conn.addHooks(ops.QueryHookFuncs{BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+		stmts = append(stmts, info.Statement)
+		return ctx, nil
+	}})
+
+	// a failed existence check does not go on to create the table
+	_, err := ks.CreateTable("views", pageView{})
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Empty(t, stmts)
+	_, err = ks.GetTable("views")
+	assert.ErrorIs(t, err, ops.ErrTableNA)
+
+	// a failed drop keeps the table
+	ks.insertTable(&Table{Name: "views", KeySpace: "ks", conn: ks.conn})
+	err = ks.DropTable("views")
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	var opErr *ops.OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, ops.OpDropTable, opErr.Op)
+	_, err = ks.GetTable("views")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,198 @@
+package cassandradb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// do runs a single statement through the hook chain of the connection.
+// run receives the prepared gocql query and returns the number of rows it read.
+func (cn *conn) do(ctx context.Context, info ops.QueryInfo, values []interface{},
+	run func(q *gocql.Query) (int, error)) error {
+
+	info.Values = len(values)
+	return cn.run(ctx, info, values, func(ctx context.Context, s *gocql.Session,
+		obs *statementObserver) (int, gocql.Consistency, error) {
+
+		q := s.Query(info.Statement, values...).WithContext(ctx).Observer(obs)
+		rows, err := run(q)
+		return rows, q.GetConsistency(), err
+	})
+}
+
+// batch runs stmts as one batch of type typ through the hook chain of the
+// connection, values holds the values of every statement.
+func (cn *conn) batch(ctx context.Context, info ops.QueryInfo, typ gocql.BatchType, stmts []string,
+	values [][]interface{}) error {
+
+	info.Values = 0
+	for _, v := range values {
+		info.Values += len(v)
+	}
+	return cn.run(ctx, info, nil, func(ctx context.Context, s *gocql.Session,
+		obs *statementObserver) (int, gocql.Consistency, error) {
+
+		b := s.NewBatch(typ).WithContext(ctx).Observer(obs)
+		for i, stmt := range stmts {
+			b.Query(stmt, values[i]...)
+		}
+		return 0, b.GetConsistency(), s.ExecuteBatch(b)
+	})
+}
+
+// run executes a statement or a batch through the hook chain of the
+// connection. exec runs it on the session and returns the number of rows
+// it read and the consistency it ran at.
+func (cn *conn) run(ctx context.Context, info ops.QueryInfo, values []interface{},
+	exec func(ctx context.Context, s *gocql.Session, obs *statementObserver) (int, gocql.Consistency, error)) error {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if info.Attempt == 0 {
+		info.Attempt = 1
+	}
+
+	ctx, span := cn.startSpan(ctx, info.Operation, info.Keyspace, info.Table)
+	span.SetAttributes(attrDBStatement.String(cn.statement(info.Statement)))
+
+	hooks := cn.queryHooks()
+	var err error
+	ran := 0
+	for _, h := range hooks {
+		var hctx context.Context
+		if hctx, err = h.Before(ctx, info); err != nil {
+			break
+		}
+		if hctx != nil {
+			ctx = hctx
+		}
+		ran++
+	}
+
+	// an open breaker fails fast, before the statement waits for its budget
+	var brk *breaker
+	if err == nil {
+		brk, err = cn.checkBreaker(info)
+	}
+	release := func() {}
+	if err == nil {
+		if release, err = cn.acquireBudget(ctx, info); err != nil && brk != nil {
+			brk.abandon()
+			brk = nil
+		}
+	}
+
+	start := time.Now()
+	rows := 0
+	obs := &statementObserver{span: span}
+	if err == nil {
+		if dbSession := cn.session(); dbSession == nil {
+			err = gocql.ErrNoConnections
+		} else {
+			var consistency gocql.Consistency
+			rows, consistency, err = exec(ctx, dbSession, obs)
+			span.SetAttributes(attrDBConsistency.String(consistencyName(consistency)))
+		}
+		release()
+	}
+	result := ops.QueryResult{
+		Rows:     rows,
+		Pages:    int(atomic.LoadInt32(&obs.pages)),
+		Retries:  int(atomic.LoadInt32(&obs.retries)),
+		Duration: time.Since(start),
+	}
+	err = wrapError(info, err)
+	if brk != nil {
+		brk.record(err)
+	}
+
+	cn.logQuery(info, result.Duration, err)
+	cn.recordQuery(info, result, err)
+	cn.checkSlowQuery(info, values, result, err)
+	for i := ran - 1; i >= 0; i-- {
+		hooks[i].After(ctx, info, result, err)
+	}
+	endSpan(span, result, err)
+	return err
+}
+
+// statementObserver counts the pages and retries of a single statement or
+// batch and adds the coordinator of every attempt to its span.
+type statementObserver struct {
+	pages   int32
+	retries int32
+	span    trace.Span
+}
+
+// ObserveQuery implements gocql.QueryObserver, it is called for every
+// attempt of every page.
+func (o *statementObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	if q.Attempt == 0 {
+		atomic.AddInt32(&o.pages, 1)
+	} else {
+		atomic.AddInt32(&o.retries, 1)
+	}
+	if o.span != nil {
+		traceAttempt(o.span, q)
+	}
+}
+
+// ObserveBatch implements gocql.BatchObserver, it is called for every
+// attempt of a batch.
+func (o *statementObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	o.ObserveQuery(ctx, gocql.ObservedQuery{Keyspace: b.Keyspace, Start: b.Start, End: b.End,
+		Host: b.Host, Err: b.Err, Attempt: b.Attempt})
+}
+
+// exec runs a statement that returns no rows.
+func (cn *conn) exec(ctx context.Context, info ops.QueryInfo, values ...interface{}) error {
+	return cn.do(ctx, info, values, func(q *gocql.Query) (int, error) {
+		return 0, q.Exec()
+	})
+}
+
+// scan runs a statement and copies the first row into dest.
+func (cn *conn) scan(ctx context.Context, info ops.QueryInfo, values []interface{}, dest ...interface{}) error {
+	return cn.do(ctx, info, values, func(q *gocql.Query) (int, error) {
+		if err := q.Scan(dest...); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
+}
+
+// iter runs a statement and calls next for every row until it returns false.
+// next is handed the iterator and must scan the row itself.
+func (cn *conn) iter(ctx context.Context, info ops.QueryInfo, values []interface{},
+	next func(iter *gocql.Iter) bool) error {
+
+	return cn.do(ctx, info, values, func(q *gocql.Query) (int, error) {
+		iter := q.Iter()
+		rows := 0
+		for next(iter) {
+			rows++
+		}
+		return rows, iter.Close()
+	})
+}
+
+// execCAS runs a lightweight transaction and fails with ops.ErrConflict
+// when its condition was not applied.
+func (cn *conn) execCAS(ctx context.Context, info ops.QueryInfo, values ...interface{}) error {
+	return cn.do(ctx, info, values, func(q *gocql.Query) (int, error) {
+		applied, err := q.MapScanCAS(make(map[string]interface{}))
+		if err != nil {
+			return 0, err
+		}
+		if !applied {
+			return 0, ops.ErrConflict
+		}
+		return 0, nil
+	})
+}
@@ -0,0 +1,103 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderHook records the calls of the hook called name in calls.
+func orderHook(name string, calls *[]string, before error) ops.QueryHook {
+	return ops.QueryHookFuncs{
+		BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+			*calls = append(*calls, name+".before")
+			return ctx, before
+		},
+		AfterFunc: func(ctx context.Context, info ops.QueryInfo, result ops.QueryResult, err error) {
+			*calls = append(*calls, name+".after")
+		},
+	}
+}
+
+func TestQueryHookOrder(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	var calls []string
+	tbl.conn.addHooks(orderHook("a", &calls, nil), orderHook("b", &calls, nil))
+
+	assert.ErrorIs(t, tbl.Insert(pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"}), ops.ErrUnavailable)
+	assert.Equal(t, []string{"a.before", "b.before", "b.after", "a.after"}, calls)
+	assert.Len(t, *stmts, 1)
+
+	// a failed Before stops the statement, only the hooks that ran see After
+	calls = nil
+	errDenied := errors.New("denied")
+	tbl.conn.addHooks(orderHook("c", &calls, errDenied), orderHook("d", &calls, nil))
+	err := tbl.Insert(pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"})
+	assert.ErrorIs(t, err, errDenied)
+	assert.NotErrorIs(t, err, ops.ErrUnavailable)
+	assert.Equal(t, []string{"a.before", "b.before", "c.before", "b.after", "a.after"}, calls)
+}
+
+func TestQueryHookInfo(t *testing.T) {
+	tbl, _ := newRecordingTable(t, pageView{})
+	var before []ops.QueryInfo
+	var after []string
+	tbl.conn.addHooks(ops.QueryHookFuncs{
+		BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+			before = append(before, info)
+			return ctx, nil
+		},
+		AfterFunc: func(ctx context.Context, info ops.QueryInfo, result ops.QueryResult, err error) {
+			after = append(after, fmt.Sprintf("%s %d %v", info.Operation, info.Attempt, errors.Is(err, ops.ErrUnavailable)))
+		},
+	})
+
+	assert.Error(t, tbl.Insert(pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"}))
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"}}
+	_, err := tbl.Read(where, nil, nil)
+	assert.Error(t, err)
+	assert.Error(t, tbl.Delete(nil, where))
+
+	require.Len(t, before, 3)
+	for i, op := range []string{ops.OpInsert, ops.OpRead, ops.OpDelete} {
+		assert.Equal(t, op, before[i].Operation)
+		assert.Equal(t, "ks", before[i].Keyspace)
+		assert.Equal(t, "views", before[i].Table)
+		assert.Equal(t, 1, before[i].Attempt)
+	}
+	assert.Equal(t, 7, before[0].Values)
+	assert.Equal(t, 1, before[1].Values)
+	assert.Equal(t, "DELETE FROM ks.views WHERE tenant = ?;", before[2].Statement)
+	assert.Equal(t, []string{"insert 1 true", "read 1 true", "delete 1 true"}, after)
+
+	// an attempt set by the caller, a retry of the bulk writer, is kept
+	err = tbl.conn.exec(context.Background(), ops.QueryInfo{Operation: ops.OpBatch, Keyspace: "ks",
+		Table: "views", Statement: "BEGIN BATCH", Attempt: 3})
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Equal(t, 3, before[3].Attempt)
+}
+
+func TestAddQueryHookReachesTables(t *testing.T) {
+	c, _ := newTestClient()
+	ks := newKeySpace("ks", c.conn)
+	entities, err := CreateEntity(pageView{})
+	require.NoError(t, err)
+	tbl := &Table{Name: "views", KeySpace: ks.Name, entities: entities, dataModel: pageView{}, conn: ks.conn,
+		stmts: newStmtCache(stmtCacheSize)}
+	bound := tbl.WithContext(context.Background())
+
+	var tables []string
+	c.AddQueryHook(ops.QueryHookFuncs{BeforeFunc: func(ctx context.Context, info ops.QueryInfo) (context.Context, error) {
+		tables = append(tables, info.Keyspace+"."+info.Table)
+		return ctx, nil
+	}})
+	assert.ErrorIs(t, tbl.Insert(pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"}), ops.ErrUnavailable)
+	assert.ErrorIs(t, bound.Insert(pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"}), ops.ErrUnavailable)
+	assert.Equal(t, []string{"ks.views", "ks.views"}, tables)
+}
@@ -0,0 +1,238 @@
+package cassandradb
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/meooio/goava/ops"
+)
+
+// Format is the file format of Export and Import.
+type Format string
+
+const (
+	// FormatJSONL writes one JSON object per row and line, keyed by column name.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes a header of the column names and one record per row.
+	// Collections are JSON encoded in their cell.
+	FormatCSV Format = "csv"
+)
+
+// Export writes every row of the table to w in format and returns the
+// number of rows written. The rows are streamed from a scan of the table,
+// in token order, a token range at a time. The columns are those selected
+// on the table, every column by default. Timestamps are written as RFC
+// 3339, blobs as base64.
+func Export(t *Table, w io.Writer, format Format) (int64, error) {
+	columns, err := exportColumns(t, ops.OpScan)
+	if err != nil {
+		return 0, err
+	}
+	enc, err := newRowEncoder(t, w, format, columns)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := exportRows(t, func(row interface{}) error {
+		return enc.encode(reflect.ValueOf(row))
+	})
+	if flushErr := enc.flush(); err == nil {
+		err = flushErr
+	}
+	return rows, err
+}
+
+// exportSplits is the number of token ranges an export reads the ring in,
+// it bounds the rows held back by a rangeStage.
+const exportSplits = 1024
+
+// exportRows scans t and passes every row to write once. A range that
+// fails is read again, the rows of the failed attempt are not written.
+func exportRows(t *Table, write func(row interface{}) error) (int64, error) {
+	stage := &rangeStage{write: write}
+	err := t.ScanWithOptions(ScanOptions{Parallelism: 1, SplitsPerWorker: exportSplits, Retries: 3,
+		RetryBackoff: 100 * time.Millisecond, RangeDone: stage.done, RangeRetry: stage.retry}, stage.add)
+	if stageErr := stage.error(); err == nil {
+		err = stageErr
+	}
+	return stage.written, err
+}
+
+// rangeStage holds the rows of the token range being read until the range
+// has been read completely, so that the rows of an attempt that failed part
+// way are dropped rather than written twice. It serves a scan of one range
+// at a time.
+type rangeStage struct {
+	sync.Mutex
+	write   func(row interface{}) error
+	rows    []interface{}
+	written int64
+	err     error
+}
+
+func (s *rangeStage) add(row interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+// retry drops the rows of the failed attempt.
+func (s *rangeStage) retry(r TokenRange, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.rows = s.rows[:0]
+}
+
+// done writes the rows of the range, the first write error stops the scan
+// at the next row.
+func (s *rangeStage) done(r TokenRange, rows int64) {
+	s.Lock()
+	defer s.Unlock()
+	for _, row := range s.rows {
+		if s.err != nil {
+			break
+		}
+		if s.err = s.write(row); s.err == nil {
+			s.written++
+		}
+	}
+	s.rows = s.rows[:0]
+}
+
+func (s *rangeStage) error() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.err
+}
+
+// exportColumns returns the entities of the plain columns selected on t.
+func exportColumns(t *Table, op string) ([]Entity, error) {
+	cols, err := t.projection(op)
+	if err != nil {
+		return nil, err
+	}
+	var columns []Entity
+	for _, col := range cols {
+		if col.kind == plainColumn {
+			columns = append(columns, col.entity)
+		}
+	}
+	return columns, nil
+}
+
+// rowEncoder writes rows of the data model in a Format.
+type rowEncoder struct {
+	columns []Entity
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	record  []string
+}
+
+func newRowEncoder(t *Table, w io.Writer, format Format, columns []Entity) (*rowEncoder, error) {
+	enc := &rowEncoder{columns: columns, buf: bufio.NewWriter(w)}
+	switch format {
+	case FormatJSONL:
+	case FormatCSV:
+		enc.csv = csv.NewWriter(enc.buf)
+		enc.record = make([]string, len(columns))
+		for i, entity := range columns {
+			enc.record[i] = entity.columnName
+		}
+		if err := enc.csv.Write(enc.record); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, t.invalidQuery(ops.OpScan, "invalid export format :: %s", format)
+	}
+	return enc, nil
+}
+
+func (enc *rowEncoder) encode(row reflect.Value) error {
+	if enc.csv != nil {
+		for i, entity := range enc.columns {
+			cell, err := csvCell(row.FieldByName(entity.fieldName))
+			if err != nil {
+				return fmt.Errorf("column %s: %w", entity.columnName, err)
+			}
+			enc.record[i] = cell
+		}
+		return enc.csv.Write(enc.record)
+	}
+
+	obj := make(map[string]interface{}, len(enc.columns))
+	for _, entity := range enc.columns {
+		obj[entity.columnName] = jsonValue(row.FieldByName(entity.fieldName))
+	}
+	line, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	enc.buf.Write(line)
+	return enc.buf.WriteByte('\n')
+}
+
+func (enc *rowEncoder) flush() error {
+	if enc.csv != nil {
+		enc.csv.Flush()
+		if err := enc.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return enc.buf.Flush()
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	bytesType         = reflect.TypeOf([]byte(nil))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonValue returns the value of field to encode in JSON.
+func jsonValue(field reflect.Value) interface{} {
+	if !field.IsValid() {
+		return nil
+	}
+	if field.Type() == timeType {
+		return field.Interface().(time.Time).UTC().Format(time.RFC3339Nano)
+	}
+	return field.Interface()
+}
+
+// csvCell returns the cell of field in a CSV record.
+func csvCell(field reflect.Value) (string, error) {
+	if !field.IsValid() {
+		return "", nil
+	}
+	switch {
+	case field.Type() == timeType:
+		return field.Interface().(time.Time).UTC().Format(time.RFC3339Nano), nil
+	case field.Type() == bytesType:
+		return base64.StdEncoding.EncodeToString(field.Bytes()), nil
+	case field.Type().Implements(textMarshalerType):
+		text, err := field.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch field.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		cell, err := json.Marshal(field.Interface())
+		return string(cell), err
+	case reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return "", nil
+		}
+		return csvCell(field.Elem())
+	}
+	return fmt.Sprint(field.Interface()), nil
+}
@@ -0,0 +1,166 @@
+package cassandradb
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportedEvent struct {
+	ID      gocql.UUID        `cql:"column_name=id,column_type=uuid,primary_key=0"`
+	At      time.Time         `cql:"column_name=at,column_type=timestamp,clustering_key=0"`
+	Name    string            `cql:"column_name=name"`
+	Count   int               `cql:"column_name=count"`
+	Score   float64           `cql:"column_name=score,column_type=double"`
+	Done    bool              `cql:"column_name=done,column_type=boolean"`
+	Tags    []string          `cql:"column_name=tags,column_type=collection,column_subtype=set,column_valuetype=text"`
+	Attrs   map[string]string `cql:"column_name=attrs,column_type=collection,column_subtype=map,column_keytype=text,column_valuetype=text"`
+	Payload []byte            `cql:"column_name=payload,column_type=blob"`
+}
+
+func exportedEvents() []exportedEvent {
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	return []exportedEvent{
+		{ID: gocql.TimeUUID(), At: at, Name: "signup, \"web\"", Count: 3, Score: 0.5, Done: true,
+			Tags: []string{"a", "b"}, Attrs: map[string]string{"k": "v"}, Payload: []byte{1, 2, 3}},
+		{ID: gocql.TimeUUID(), At: at.Add(time.Hour), Name: "login"},
+	}
+}
+
+// encodeRows encodes rows the way Export writes the rows of a scan.
+func encodeRows(t *testing.T, tbl *Table, format Format, rows []exportedEvent) string {
+	columns, err := exportColumns(tbl, ops.OpScan)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	enc, err := newRowEncoder(tbl, &out, format, columns)
+	require.NoError(t, err)
+	for i := range rows {
+		require.NoError(t, enc.encode(reflect.ValueOf(rows[i])))
+	}
+	require.NoError(t, enc.flush())
+	return out.String()
+}
+
+// importRows imports data and returns the rows handed to the writer, which
+// fail without a session.
+func importRows(t *testing.T, tbl *Table, format Format, data string, opts ImportOptions) ([]exportedEvent, ImportReport) {
+	var mu sync.Mutex
+	var rows []exportedEvent
+	opts.DeadLetter = func(row interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		rows = append(rows, row.(exportedEvent))
+	}
+	report, err := Import(tbl, strings.NewReader(data), format, opts)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	return rows, report
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatCSV, FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			tbl, _ := newRecordingTable(t, exportedEvent{})
+			events := exportedEvents()
+			data := encodeRows(t, tbl, format, events)
+
+			rows, report := importRows(t, tbl, format, data, ImportOptions{BatchSize: 1})
+			assert.Equal(t, int64(2), report.Rows)
+			assert.Equal(t, int64(2), report.Failed)
+			assert.Empty(t, report.Errors)
+			require.Len(t, rows, 2)
+			if rows[0].Name != events[0].Name {
+				rows[0], rows[1] = rows[1], rows[0]
+			}
+			assert.Equal(t, events[0], rows[0])
+			// zero collections come back nil or empty depending on the format
+			assert.Equal(t, events[1].ID, rows[1].ID)
+			assert.True(t, events[1].At.Equal(rows[1].At))
+			assert.Empty(t, rows[1].Tags)
+		})
+	}
+}
+
+func TestExportCSVHeaderAndCells(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	events := exportedEvents()
+	lines := strings.Split(encodeRows(t, tbl, FormatCSV, events[:1]), "\n")
+	assert.Equal(t, "id,at,name,count,score,done,tags,attrs,payload", lines[0])
+	assert.Equal(t, events[0].ID.String()+`,2024-05-01T10:30:00Z,"signup, ""web""",3,0.5,true,"[""a"",""b""]","{""k"":""v""}",AQID`,
+		lines[1])
+
+	jsonl := encodeRows(t, tbl.Select("name", "at"), FormatJSONL, events[:1])
+	assert.Equal(t, `{"at":"2024-05-01T10:30:00Z","name":"signup, \"web\""}`+"\n", jsonl)
+
+	_, err := newRowEncoder(tbl, &bytes.Buffer{}, Format("xml"), nil)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestImportMappingAndCoercionErrors(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	id := gocql.TimeUUID()
+	data := "event_id,At,name,count,source\n" +
+		id.String() + ",2024-05-01T10:30:00Z,ok,7,web\n" +
+		id.String() + ",yesterday,bad,seven,web\n" +
+		"short\n"
+
+	rows, report := importRows(t, tbl, FormatCSV, data, ImportOptions{Columns: map[string]string{"event_id": "id"}})
+	require.Len(t, rows, 1)
+	assert.Equal(t, id, rows[0].ID)
+	assert.Equal(t, 7, rows[0].Count)
+	assert.Equal(t, int64(3), report.Rows)
+	assert.Equal(t, int64(2), report.Skipped)
+	assert.Equal(t, []string{"source"}, report.Ignored)
+	require.Len(t, report.Errors, 3)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Equal(t, "at", report.Errors[0].Column)
+	assert.Equal(t, "yesterday", report.Errors[0].Value)
+	assert.Equal(t, "count", report.Errors[1].Column)
+	assert.Equal(t, 4, report.Errors[2].Line)
+
+	bad := `{"id":"` + id.String() + `","count":"seven"}` + "\n" + "{not json\n"
+	_, err := Import(tbl, strings.NewReader(bad), FormatJSONL, ImportOptions{MaxErrors: 1})
+	assert.ErrorIs(t, err, errTooManyErrors)
+}
+
+func TestRangeStageDropsFailedAttempts(t *testing.T) {
+	var written []string
+	stage := &rangeStage{write: func(row interface{}) error {
+		written = append(written, row.(exportedEvent).Name)
+		return nil
+	}}
+	first := TokenRange{Start: math.MinInt64, End: 0}
+	second := TokenRange{Start: 0, End: math.MaxInt64}
+
+	// the first range times out after two rows and is read again
+	require.NoError(t, stage.add(exportedEvent{Name: "a"}))
+	require.NoError(t, stage.add(exportedEvent{Name: "b"}))
+	stage.retry(first, ops.ErrTimeout)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, stage.add(exportedEvent{Name: name}))
+	}
+	stage.done(first, 3)
+	require.NoError(t, stage.add(exportedEvent{Name: "d"}))
+	stage.done(second, 1)
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, written)
+	assert.Equal(t, int64(4), stage.written)
+	assert.NoError(t, stage.error())
+
+	// a failed write stops the scan at its next row
+	errFull := errors.New("disk full")
+	stage = &rangeStage{write: func(row interface{}) error { return errFull }}
+	require.NoError(t, stage.add(exportedEvent{Name: "a"}))
+	stage.done(first, 1)
+	assert.ErrorIs(t, stage.add(exportedEvent{Name: "b"}), errFull)
+	assert.Zero(t, stage.written)
+}
@@ -0,0 +1,296 @@
+package cassandradb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/meooio/goava/ops"
+)
+
+// ImportOptions controls an Import.
+type ImportOptions struct {
+	// Columns maps the fields of the input to column names. Fields named
+	// like a column need no entry, fields that are neither mapped nor a
+	// column are ignored.
+	Columns map[string]string
+	// BatchSize is the maximum number of rows written in one batch,
+	// defaults to 20.
+	BatchSize int
+	// Concurrency is the number of batches written at the same time,
+	// defaults to 4.
+	Concurrency int
+	// MaxErrors stops the import once that many rows could not be read,
+	// zero never stops.
+	MaxErrors int
+	// DeadLetter is called with every row the database did not write.
+	DeadLetter func(row interface{}, err error)
+}
+
+// ImportError reports a row of the input that could not be read, or one
+// of its values that could not be coerced to the type of its column.
+type ImportError struct {
+	Line   int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e ImportError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: column %s: invalid value %q: %v", e.Line, e.Column, e.Value, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReport summarizes an Import.
+type ImportReport struct {
+	// Rows is the number of rows read from the input
+	Rows    int64
+	Written int64
+	// Skipped counts the rows not written because they could not be read
+	Skipped int64
+	// Failed counts the rows the database did not write
+	Failed int64
+	// Errors describes every row skipped
+	Errors []ImportError
+	// Ignored lists the fields of the input that are not columns
+	Ignored []string
+}
+
+// Import reads rows in format from r and inserts them into the table
+// through a BulkWriter. Values are coerced to the types of the data model,
+// a row with a value that cannot be coerced is skipped and reported. The
+// error is the first write failure, or why the input could not be read.
+func Import(t *Table, r io.Reader, format Format, opts ImportOptions) (ImportReport, error) {
+	imp := &importer{table: t, opts: opts, ignored: make(map[string]bool)}
+	switch format {
+	case FormatCSV, FormatJSONL:
+	default:
+		return ImportReport{}, t.invalidQuery(ops.OpBatch, "invalid import format :: %s", format)
+	}
+	imp.writer = t.NewBulkWriter(BulkOptions{BatchSize: opts.BatchSize, Concurrency: opts.Concurrency,
+		DeadLetter: opts.DeadLetter})
+
+	var err error
+	if format == FormatCSV {
+		err = imp.readCSV(r)
+	} else {
+		err = imp.readJSONL(r)
+	}
+	bulk, writeErr := imp.writer.Close()
+	imp.report.Written = bulk.Written
+	imp.report.Failed = bulk.Failed
+	for name := range imp.ignored {
+		imp.report.Ignored = append(imp.report.Ignored, name)
+	}
+	sort.Strings(imp.report.Ignored)
+	if err == nil {
+		err = writeErr
+	}
+	return imp.report, err
+}
+
+var errTooManyErrors = errors.New("too many rows could not be read")
+
+type importer struct {
+	table   *Table
+	opts    ImportOptions
+	writer  *BulkWriter
+	report  ImportReport
+	ignored map[string]bool
+}
+
+// column returns the entity the input field name is mapped to.
+func (imp *importer) column(name string) (Entity, bool) {
+	column := name
+	if mapped, ok := imp.opts.Columns[name]; ok {
+		column = mapped
+	}
+	entity, ok := imp.table.entity(strings.ToLower(column))
+	if !ok {
+		imp.ignored[name] = true
+	}
+	return entity, ok
+}
+
+// add writes row, or reports errs when the row could not be read.
+func (imp *importer) add(row reflect.Value, errs []ImportError) error {
+	imp.report.Rows++
+	if len(errs) > 0 {
+		imp.report.Skipped++
+		imp.report.Errors = append(imp.report.Errors, errs...)
+		if imp.opts.MaxErrors > 0 && imp.report.Skipped >= int64(imp.opts.MaxErrors) {
+			return errTooManyErrors
+		}
+		return nil
+	}
+	return imp.writer.Write(row.Interface())
+}
+
+func (imp *importer) newRow() reflect.Value {
+	return reflect.New(reflect.TypeOf(imp.table.dataModel)).Elem()
+}
+
+func (imp *importer) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make([]*Entity, len(header))
+	for i, name := range header {
+		if entity, ok := imp.column(strings.TrimSpace(name)); ok {
+			columns[i] = &entity
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+			if err := imp.add(reflect.Value{}, []ImportError{{Line: parseErr.StartLine, Err: csv.ErrFieldCount}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+
+		row := imp.newRow()
+		var errs []ImportError
+		for i, cell := range record {
+			entity := columns[i]
+			if entity == nil {
+				continue
+			}
+			field := row.FieldByName(entity.fieldName)
+			if err := coerceText(field, cell); err != nil {
+				errs = append(errs, ImportError{Line: line, Column: entity.columnName, Value: cell, Err: err})
+			}
+		}
+		if err := imp.add(row, errs); err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *importer) readJSONL(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			if err := imp.add(reflect.Value{}, []ImportError{{Line: line, Err: err}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		row := imp.newRow()
+		var errs []ImportError
+		for name, raw := range obj {
+			entity, ok := imp.column(name)
+			if !ok {
+				continue
+			}
+			field := row.FieldByName(entity.fieldName)
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+				errs = append(errs, ImportError{Line: line, Column: entity.columnName, Value: string(raw), Err: err})
+			}
+		}
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Column < errs[j].Column })
+		if err := imp.add(row, errs); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// coerceText sets field to the value of a CSV cell, the reverse of csvCell.
+// An empty cell leaves the field zero.
+func coerceText(field reflect.Value, text string) error {
+	if text == "" {
+		return nil
+	}
+	switch {
+	case field.Type() == bytesType:
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return err
+		}
+		field.SetBytes(b)
+		return nil
+	case field.Addr().Type().Implements(textUnmarshalerType):
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return json.Unmarshal([]byte(text), field.Addr().Interface())
+	case reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := coerceText(elem.Elem(), text); err != nil {
+			return err
+		}
+		field.Set(elem)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -2,9 +2,9 @@ package cassandradb
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
@@ -14,14 +14,18 @@ import (
 
 type KeySpace struct {
 	sync.RWMutex
-	dbSession *gocql.Session
+	conn      *conn
 	Name      string
 	Tables    map[string]*Table
 	Backups   []string
 }
 
 func GetKeySpace(name string, dbSession *gocql.Session) *KeySpace {
-	ks := KeySpace{dbSession: dbSession,
+	return newKeySpace(name, newConn(dbSession))
+}
+
+func newKeySpace(name string, cn *conn) *KeySpace {
+	ks := KeySpace{conn: cn,
 		Name:   name,
 		Tables: make(map[string]*Table)}
 
@@ -31,9 +35,13 @@ func GetKeySpace(name string, dbSession *gocql.Session) *KeySpace {
 
 // doesTableExist check to see if a given column family exists.
 func (k *KeySpace) DoesTableExist(keySpace string, tableName string) (bool, error) {
+	return k.doesTableExist(context.Background(), keySpace, tableName)
+}
+
+func (k *KeySpace) doesTableExist(ctx context.Context, keySpace string, tableName string) (bool, error) {
 
-	if k.dbSession == nil {
-		return false, errors.New("No valid session found")
+	if k.conn.session() == nil {
+		return false, ops.NewOpError(ops.OpTableExists, keySpace, tableName, ops.ErrUnavailable, "no valid session found")
 	}
 	var name string
 	queryString := fmt.Sprintf("SELECT table_name FROM "+
@@ -41,21 +49,29 @@ func (k *KeySpace) DoesTableExist(keySpace string, tableName string) (bool, erro
 		"table_name = '%s' ALLOW FILTERING",
 		keySpace, tableName)
 
-	err := k.dbSession.Query(queryString).Consistency(gocql.One).Scan(&name)
+	info := ops.QueryInfo{Operation: ops.OpTableExists, Keyspace: keySpace, Table: tableName, Statement: queryString}
+	err := k.conn.do(ctx, info, nil, func(q *gocql.Query) (int, error) {
+		if err := q.Consistency(gocql.One).Scan(&name); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
 	if err != nil {
-		if err.Error() == "not found" {
+		if errors.Is(err, ops.ErrNotFound) {
 			return false, nil
 		}
-		log.Printf("could not read from system table: %v", err)
 		return false, err
 	}
 	if len(name) > 0 {
-		log.Printf("table exists: %s", name)
 		return true, nil
 	}
 	return false, nil
 }
 
+func (k *KeySpace) queryInfo(op string, tableName string, stmt string) ops.QueryInfo {
+	return ops.QueryInfo{Operation: op, Keyspace: k.Name, Table: tableName, Statement: stmt}
+}
+
 func (k *KeySpace) insertTable(t *Table) {
 	k.Lock()
 	defer k.Unlock()
@@ -70,15 +86,26 @@ func (k *KeySpace) removeTable(tableName string) {
 
 // Create a cassandra database
 func (k *KeySpace) CreateTable(tableName string, tableModel interface{}) (ops.Table, error) {
+	var table ops.Table
+	// the existence check, the table and its indexes are traced below one span
+	err := k.conn.traceCall(context.Background(), ops.OpCreateTable, k.Name, tableName, func(ctx context.Context) error {
+		var err error
+		table, err = k.createTable(ctx, tableName, tableModel)
+		return err
+	})
+	return table, err
+}
 
+func (k *KeySpace) createTable(ctx context.Context, tableName string, tableModel interface{}) (ops.Table, error) {
 	if k.Name == "" {
 		return nil, ops.ErrInvalidKeyspace
 	}
 
 	entities, err := CreateEntity(tableModel)
 	if err != nil {
-		log.Printf("Error creating table %s : %s", tableName, err)
-		return nil, err
+		k.conn.log().Log(ops.LevelError, "invalid table model", ops.F(ops.FieldKeyspace, k.Name),
+			ops.F(ops.FieldTable, tableName), ops.F(ops.FieldError, err))
+		return nil, &ops.OpError{Op: ops.OpCreateTable, Keyspace: k.Name, Table: tableName, Kind: ops.ErrInvalidQuery, Err: err}
 	}
 
 	now := time.Now()
@@ -87,15 +114,18 @@ func (k *KeySpace) CreateTable(tableName string, tableModel interface{}) (ops.Ta
 		entities:  entities,
 		createdAt: now,
 		updatedAt: now,
-		dbSession: k.dbSession,
-		dataModel: tableModel}
+		conn:      k.conn,
+		dataModel: tableModel,
+		stmts:     newStmtCache(stmtCacheSize)}
 
-	exists, _ := k.DoesTableExist(k.Name, tableName)
+	exists, err := k.doesTableExist(ctx, k.Name, tableName)
+	if err != nil {
+		return nil, err
+	}
 	if exists {
 		k.insertTable(table)
 		return table, ops.ErrTableExist
 	}
-	log.Printf("creating table: %s", tableName)
 
 	// column := make([]string, len(entities))
 	// ctype := make([]string, len(entities))
@@ -116,24 +146,12 @@ func (k *KeySpace) CreateTable(tableName string, tableModel interface{}) (ops.Ta
 	buffer.WriteString(tableName)
 	buffer.WriteString(" ( ")
 	for _, entity := range entities {
-		if entity.columnType == "collection" {
-			if entity.columnSubType == "map" {
-				field := fmt.Sprintf(" %s map<%s,%s> ", entity.columnName, entity.columnKeyType, entity.columnValType)
-				buffer.WriteString(field)
-			} else if entity.columnSubType == "set" {
-				field := fmt.Sprintf(" %s set<%s> ", entity.columnName, entity.columnValType)
-				buffer.WriteString(field)
-			} else if entity.columnSubType == "list" {
-				field := fmt.Sprintf(" %s list<%s> ", entity.columnName, entity.columnValType)
-				buffer.WriteString(field)
-			} else {
-				return nil, errors.New("invalid collection type : entity.columnSubType")
-			}
-		} else {
-			// fmt.Printf(" COL KEY VAL :: %s %s \n", entity.columnName, entity.columnType)
-			field := fmt.Sprintf(" %s %s ", entity.columnName, entity.columnType)
-			buffer.WriteString(field)
+		def, ok := columnDefinition(entity)
+		if !ok {
+			return nil, ops.NewOpError(ops.OpCreateTable, k.Name, tableName, ops.ErrInvalidQuery,
+				"invalid collection type : %s", entity.columnSubType)
 		}
+		buffer.WriteString(fmt.Sprintf(" %s %s ", entity.columnName, def))
 		buffer.WriteString(", ")
 
 		if entity.primaryKey {
@@ -219,26 +237,20 @@ func (k *KeySpace) CreateTable(tableName string, tableModel interface{}) (ops.Ta
 		buffer.WriteString(")")
 	}
 	buffer.WriteString(";")
-	fmt.Println("CREATE TABLE: " + buffer.String() + "\n")
 	queryStr := buffer.String()
-	query, err := CreateQuery(k.dbSession, queryStr)
-	if err != nil {
-		return nil, err
-	}
-
-	tableCreateErr := ExecQuery(query)
+	tableCreateErr := k.conn.exec(ctx, k.queryInfo(ops.OpCreateTable, tableName, queryStr))
 
 	if tableCreateErr != nil {
 		return nil, tableCreateErr
 	}
 
 	iklen := len(iks)
-	log.Printf("index length :: %d\n", iklen)
 	if iklen > 0 { // table has index columns
 		for _, ik := range iks {
-			log.Printf("index key :: %s\n", ik)
 			if ik != "" {
-				CreateIndex(k.dbSession, k.Name, tableName, ik)
+				// a failed index is logged by the executor and does not fail the table
+				k.conn.exec(ctx, k.queryInfo(ops.OpCreateIndex, tableName,
+					createIndexStmt(k.Name, tableName, ik)))
 			}
 		}
 	}
@@ -249,14 +261,9 @@ func (k *KeySpace) CreateTable(tableName string, tableModel interface{}) (ops.Ta
 // Drop a cassandra database table
 func (k *KeySpace) DropTable(tableName string) error {
 	dropStr := fmt.Sprintf("DROP TABLE %s.%s", k.Name, tableName)
-	cassQuery, err := CreateQuery(k.dbSession, dropStr)
-	if err != nil {
+	if err := k.conn.exec(context.Background(), k.queryInfo(ops.OpDropTable, tableName, dropStr)); err != nil {
 		return err
 	}
-	if err = ExecQuery(cassQuery); err != nil {
-		log.Printf("could not drop table: %s :: %v", tableName, err)
-	}
-	log.Printf("dropped table: %s", tableName)
 
 	// remove entry from keyspace map
 	k.removeTable(tableName)
@@ -278,17 +285,17 @@ func (k *KeySpace) GetTable(tableName string) (ops.Table, error) {
 }
 
 func (k *KeySpace) AlterTable(tableName string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpAlterTable, Keyspace: k.Name, Table: tableName, Kind: ops.ErrNoSupport}
 }
 
 func (k *KeySpace) RestoreTable(tableName string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpRestore, Keyspace: k.Name, Table: tableName, Kind: ops.ErrNoSupport}
 }
 
 func (k *KeySpace) BackupDB(name string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpBackup, Keyspace: name, Kind: ops.ErrNoSupport}
 }
 
 func (k *KeySpace) RestoreDB(name string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpRestore, Keyspace: name, Kind: ops.ErrNoSupport}
 }
@@ -0,0 +1,102 @@
+package cassandradb
+
+import (
+	"reflect"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+// rowPointer returns data when it is a pointer, or a pointer to a copy of
+// it, for the lifecycle hooks of the model to change the row.
+func rowPointer(data interface{}) interface{} {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() || v.Kind() == reflect.Ptr {
+		return data
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Interface()
+}
+
+// hookError returns the error of a lifecycle hook of op.
+func (t *Table) hookError(op string, err error) error {
+	return wrapError(t.queryInfo(op, ""), err)
+}
+
+// beforeInsert calls the BeforeInsert hook of data and returns the row to
+// insert.
+func (t *Table) beforeInsert(data interface{}) (interface{}, error) {
+	row := rowPointer(data)
+	if h, ok := row.(ops.BeforeInserter); ok {
+		if err := h.BeforeInsert(); err != nil {
+			return nil, t.hookError(ops.OpInsert, err)
+		}
+	}
+	return row, nil
+}
+
+func (t *Table) afterInsert(row interface{}) error {
+	if h, ok := row.(ops.AfterInserter); ok {
+		if err := h.AfterInsert(); err != nil {
+			return t.hookError(ops.OpInsert, err)
+		}
+	}
+	return nil
+}
+
+// beforeUpdate calls the BeforeUpdate hook of data and returns the row to
+// update.
+func (t *Table) beforeUpdate(data interface{}) (interface{}, error) {
+	row := rowPointer(data)
+	if h, ok := row.(ops.BeforeUpdater); ok {
+		if err := h.BeforeUpdate(); err != nil {
+			return nil, t.hookError(ops.OpUpdate, err)
+		}
+	}
+	return row, nil
+}
+
+func (t *Table) afterUpdate(row interface{}) error {
+	if h, ok := row.(ops.AfterUpdater); ok {
+		if err := h.AfterUpdate(); err != nil {
+			return t.hookError(ops.OpUpdate, err)
+		}
+	}
+	return nil
+}
+
+// deleteModel returns the zero value of the data model the delete hooks
+// are called on.
+func (t *Table) deleteModel() interface{} {
+	return reflect.New(reflect.TypeOf(t.dataModel)).Interface()
+}
+
+func (t *Table) beforeDelete(model interface{}, whereClause []whc.WhereClauseType) error {
+	if h, ok := model.(ops.BeforeDeleter); ok {
+		if err := h.BeforeDelete(whereClause); err != nil {
+			return t.hookError(ops.OpDelete, err)
+		}
+	}
+	return nil
+}
+
+func (t *Table) afterDelete(model interface{}, whereClause []whc.WhereClauseType) error {
+	if h, ok := model.(ops.AfterDeleter); ok {
+		if err := h.AfterDelete(whereClause); err != nil {
+			return t.hookError(ops.OpDelete, err)
+		}
+	}
+	return nil
+}
+
+// afterRead calls the AfterRead hook of row, an addressable value of the
+// data model.
+func (t *Table) afterRead(op string, row reflect.Value) error {
+	if h, ok := row.Addr().Interface().(ops.AfterReader); ok {
+		if err := h.AfterRead(); err != nil {
+			return t.hookError(op, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cassandradb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errHook = errors.New("hook failed")
+
+type hookedNote struct {
+	ID    string `cql:"column_name=id,primary_key=0"`
+	Title string `cql:"column_name=title"`
+	Slug  string `cql:"column_name=slug" validate:"required"`
+	// Reads counts the AfterRead calls
+	Reads int
+}
+
+func (n *hookedNote) BeforeInsert() error {
+	if n.Title == "fail" {
+		return errHook
+	}
+	n.Title = strings.TrimSpace(n.Title)
+	n.Slug = strings.ToLower(strings.ReplaceAll(n.Title, " ", "-"))
+	return nil
+}
+
+func (n *hookedNote) AfterInsert() error {
+	if n.Title == "after" {
+		return errHook
+	}
+	return nil
+}
+
+func (n *hookedNote) BeforeUpdate() error {
+	return n.BeforeInsert()
+}
+
+func (n *hookedNote) BeforeDelete(whereClause []whc.WhereClauseType) error {
+	if len(whereClause) > 0 && whereClause[0].ColumnValue == "locked" {
+		return errHook
+	}
+	return nil
+}
+
+func (n *hookedNote) AfterRead() error {
+	n.Reads++
+	if n.ID == "fail" {
+		return errHook
+	}
+	return nil
+}
+
+func TestLifecycleBeforeHooks(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, hookedNote{})
+
+	// the slug is derived before validation
+	note := hookedNote{ID: "n1", Title: "  Hello World "}
+	assert.ErrorIs(t, tbl.Insert(note), ops.ErrUnavailable)
+	assert.Equal(t, "  Hello World ", note.Title)
+	assert.ErrorIs(t, tbl.Insert(&note), ops.ErrUnavailable)
+	assert.Equal(t, "hello-world", note.Slug)
+	assert.ErrorIs(t, tbl.InsertIfNotExists(&hookedNote{ID: "n2", Title: "x"}), ops.ErrUnavailable)
+	assert.ErrorIs(t, tbl.Update(hookedNote{ID: "n1", Title: "Renamed"}), ops.ErrUnavailable)
+	require.Len(t, *stmts, 4)
+
+	// a failing hook aborts the write
+	err := tbl.Insert(hookedNote{ID: "n3", Title: "fail"})
+	assert.ErrorIs(t, err, errHook)
+	var opErr *ops.OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, ops.OpInsert, opErr.Op)
+	assert.ErrorIs(t, tbl.InsertIfNotExists(&hookedNote{ID: "n3", Title: "fail"}), errHook)
+	assert.ErrorIs(t, tbl.Update(&hookedNote{ID: "n3", Title: "fail"}), errHook)
+	where := []whc.WhereClauseType{{ColumnName: "id", RelationType: "=", ColumnValue: "locked"}}
+	assert.ErrorIs(t, tbl.Delete(nil, where), errHook)
+	assert.Len(t, *stmts, 4)
+
+	where[0].ColumnValue = "n1"
+	assert.ErrorIs(t, tbl.Delete(nil, where), ops.ErrUnavailable)
+	assert.Len(t, *stmts, 5)
+}
+
+func TestLifecycleAfterHooks(t *testing.T) {
+	tbl, _ := newRecordingTable(t, hookedNote{})
+
+	assert.NoError(t, tbl.afterInsert(&hookedNote{}))
+	assert.ErrorIs(t, tbl.afterInsert(&hookedNote{Title: "after"}), errHook)
+	assert.NoError(t, tbl.afterUpdate(&hookedNote{}))
+
+	rows := reflect.ValueOf([]hookedNote{{ID: "a"}, {ID: "fail"}})
+	assert.NoError(t, tbl.afterRead(ops.OpList, rows.Index(0)))
+	assert.ErrorIs(t, tbl.afterRead(ops.OpList, rows.Index(1)), errHook)
+	assert.Equal(t, 1, rows.Index(0).Interface().(hookedNote).Reads)
+
+	// models without hooks
+	plain, _ := newRecordingTable(t, pageView{})
+	row := reflect.New(reflect.TypeOf(pageView{})).Elem()
+	assert.NoError(t, plain.afterRead(ops.OpRead, row))
+	assert.NoError(t, plain.beforeDelete(plain.deleteModel(), nil))
+}
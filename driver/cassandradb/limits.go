@@ -0,0 +1,195 @@
+package cassandradb
+
+import (
+	"context"
+	"time"
+
+	"github.com/meooio/goava/ops"
+)
+
+// LimitConfig is the budget of a class of statements or of a table.
+type LimitConfig struct {
+	// Rate is the number of statements per second, unlimited when zero.
+	Rate float64 `toml:"rate"`
+	// Burst is the number of statements allowed at once above Rate,
+	// defaults to 1.
+	Burst int `toml:"burst"`
+	// MaxInFlight caps the statements running at the same time, unlimited
+	// when zero.
+	MaxInFlight int `toml:"max_in_flight"`
+}
+
+func (l LimitConfig) enabled() bool {
+	return l.Rate > 0 || l.MaxInFlight > 0
+}
+
+// LimitsConfig sets the client side budgets of a client. A statement has
+// to fit in the budget of its operation class and in the budget of its
+// table.
+type LimitsConfig struct {
+	// Wait makes statements wait for their budget, bounded by their
+	// context. Otherwise they fail right away with ops.ErrRateLimited.
+	Wait   bool        `toml:"wait"`
+	Reads  LimitConfig `toml:"reads"`
+	Writes LimitConfig `toml:"writes"`
+	DDL    LimitConfig `toml:"ddl"`
+	// Tables holds the budgets of single tables, by keyspace.table, so that
+	// tables of the same name in two keyspaces have budgets of their own.
+	Tables map[string]LimitConfig `toml:"tables"`
+}
+
+// budget enforces a LimitConfig.
+type budget struct {
+	name     string
+	labels   ops.Labels
+	bucket   *tokenBucket
+	inFlight chan struct{}
+}
+
+func newBudget(name string, cfg LimitConfig) *budget {
+	b := &budget{name: name, labels: ops.Labels{ops.LabelBudget: name}}
+	if cfg.Rate > 0 {
+		b.bucket = newTokenBucket(cfg.Rate, cfg.Burst)
+	}
+	if cfg.MaxInFlight > 0 {
+		b.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return b
+}
+
+// limiter holds the budgets of a client.
+type limiter struct {
+	wait    bool
+	classes map[string]*budget
+	tables  map[string]*budget
+}
+
+func newLimiter(cfg LimitsConfig) *limiter {
+	l := &limiter{wait: cfg.Wait, classes: make(map[string]*budget), tables: make(map[string]*budget)}
+	for class, c := range map[string]LimitConfig{ops.ClassRead: cfg.Reads, ops.ClassWrite: cfg.Writes, ops.ClassDDL: cfg.DDL} {
+		if c.enabled() {
+			l.classes[class] = newBudget(class, c)
+		}
+	}
+	for table, c := range cfg.Tables {
+		if c.enabled() {
+			l.tables[table] = newBudget("table:"+table, c)
+		}
+	}
+	if len(l.classes) == 0 && len(l.tables) == 0 {
+		return nil
+	}
+	return l
+}
+
+// acquire takes the budgets of the statement described by info. The
+// returned function gives back the in-flight slots and must be called once
+// the statement has finished. A statement rejected by one budget gives
+// back what it took of the others.
+func (l *limiter) acquire(ctx context.Context, info ops.QueryInfo, m ops.Metrics) (func(), error) {
+	var budgets []*budget
+	if b, ok := l.classes[ops.OperationClass(info.Operation)]; ok {
+		budgets = append(budgets, b)
+	}
+	if b, ok := l.tables[info.Keyspace+"."+info.Table]; ok && info.Table != "" {
+		budgets = append(budgets, b)
+	}
+
+	var held []*budget
+	release := func() {
+		for _, b := range held {
+			<-b.inFlight
+			m.Set(ops.MetricBudgetInUse, b.labels, float64(len(b.inFlight)))
+		}
+	}
+	for _, b := range budgets {
+		if err := l.take(ctx, b, m); err != nil {
+			release()
+			for _, h := range budgets {
+				if h == b {
+					break
+				}
+				if h.bucket != nil {
+					h.bucket.cancel()
+				}
+			}
+			m.Add(ops.MetricRateLimited, b.labels, 1)
+			return nil, err
+		}
+		if b.inFlight != nil {
+			held = append(held, b)
+		}
+	}
+	return release, nil
+}
+
+// take takes a token and an in-flight slot of b, or neither.
+func (l *limiter) take(ctx context.Context, b *budget, m ops.Metrics) error {
+	start := time.Now()
+	if b.bucket != nil {
+		if l.wait {
+			if err := b.bucket.wait(ctx); err != nil {
+				return err
+			}
+		} else if !b.bucket.allow() {
+			return ops.ErrRateLimited
+		}
+		m.Set(ops.MetricBudgetTokens, b.labels, b.bucket.available())
+	}
+	if b.inFlight != nil {
+		var err error
+		if l.wait {
+			select {
+			case b.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		} else {
+			select {
+			case b.inFlight <- struct{}{}:
+			default:
+				err = ops.ErrRateLimited
+			}
+		}
+		if err != nil {
+			if b.bucket != nil {
+				b.bucket.cancel()
+			}
+			return err
+		}
+		m.Set(ops.MetricBudgetInUse, b.labels, float64(len(b.inFlight)))
+	}
+	if l.wait {
+		m.Observe(ops.MetricBudgetWait, b.labels, time.Since(start).Seconds())
+	}
+	return nil
+}
+
+func (cn *conn) setLimits(cfg LimitsConfig) {
+	l := newLimiter(cfg)
+	cn.Lock()
+	defer cn.Unlock()
+	cn.limits = l
+}
+
+func (cn *conn) limiter() *limiter {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.limits
+}
+
+// acquireBudget takes the client budgets of a statement. Statements over
+// budget fail with an error of class ops.ErrRateLimited, or with the error
+// of ctx when they waited for their budget.
+func (cn *conn) acquireBudget(ctx context.Context, info ops.QueryInfo) (func(), error) {
+	l := cn.limiter()
+	if l == nil {
+		return func() {}, nil
+	}
+	release, err := l.acquire(ctx, info, cn.metricSink())
+	if err == ops.ErrRateLimited {
+		return nil, ops.NewOpError(info.Operation, info.Keyspace, info.Table, ops.ErrRateLimited,
+			"over the client budget")
+	}
+	return release, err
+}
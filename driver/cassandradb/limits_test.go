@@ -0,0 +1,97 @@
+package cassandradb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/metrics"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedStatementsFail(t *testing.T) {
+	m := metrics.NewPrometheus()
+	cn := newConn(nil)
+	cn.setMetrics(m)
+	cn.setLimits(LimitsConfig{Reads: LimitConfig{Rate: 0.001, Burst: 1}})
+
+	read := ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "users", Statement: "SELECT * FROM ks.users"}
+	// the first read gets the burst token and fails for lack of a session
+	assert.ErrorIs(t, cn.exec(context.Background(), read), ops.ErrUnavailable)
+	err := cn.exec(context.Background(), read)
+	assert.ErrorIs(t, err, ops.ErrRateLimited)
+	assert.Equal(t, "rate_limited", ops.ErrorClass(err))
+
+	// writes have a budget of their own
+	write := ops.QueryInfo{Operation: ops.OpInsert, Keyspace: "ks", Table: "users", Statement: "INSERT"}
+	assert.ErrorIs(t, cn.exec(context.Background(), write), ops.ErrUnavailable)
+
+	assert.Equal(t, 1.0, m.Value(ops.MetricRateLimited, ops.Labels{ops.LabelBudget: ops.ClassRead}))
+}
+
+func TestRateLimitWaitHonoursContext(t *testing.T) {
+	cn := newConn(nil)
+	cn.setLimits(LimitsConfig{Wait: true, Tables: map[string]LimitConfig{"ks.users": {Rate: 0.001}}})
+	info := ops.QueryInfo{Operation: ops.OpList, Keyspace: "ks", Table: "users", Statement: "SELECT"}
+	cn.exec(context.Background(), info)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, cn.exec(ctx, info), ops.ErrTimeout)
+
+	// other tables are not limited, nor tables of the same name in other keyspaces
+	other := ops.QueryInfo{Operation: ops.OpList, Keyspace: "ks", Table: "orders", Statement: "SELECT"}
+	assert.ErrorIs(t, cn.exec(ctx, other), ops.ErrUnavailable)
+	other = ops.QueryInfo{Operation: ops.OpList, Keyspace: "archive", Table: "users", Statement: "SELECT"}
+	assert.ErrorIs(t, cn.exec(ctx, other), ops.ErrUnavailable)
+}
+
+func TestInFlightCap(t *testing.T) {
+	m := metrics.NewPrometheus()
+	l := newLimiter(LimitsConfig{Writes: LimitConfig{MaxInFlight: 1}})
+	require.NotNil(t, l)
+	info := ops.QueryInfo{Operation: ops.OpUpdate, Table: "users"}
+
+	release, err := l.acquire(context.Background(), info, m)
+	require.NoError(t, err)
+	labels := ops.Labels{ops.LabelBudget: ops.ClassWrite}
+	assert.Equal(t, 1.0, m.Value(ops.MetricBudgetInUse, labels))
+	_, err = l.acquire(context.Background(), info, m)
+	assert.Equal(t, ops.ErrRateLimited, err)
+
+	release()
+	assert.Equal(t, 0.0, m.Value(ops.MetricBudgetInUse, labels))
+	release, err = l.acquire(context.Background(), info, m)
+	require.NoError(t, err)
+	release()
+
+	assert.Nil(t, newLimiter(LimitsConfig{}))
+}
+
+func TestRejectedStatementGivesBackTokens(t *testing.T) {
+	l := newLimiter(LimitsConfig{
+		Reads:  LimitConfig{Rate: 0.001, Burst: 2},
+		Tables: map[string]LimitConfig{"ks.users": {MaxInFlight: 1}, "ks.orders": {Rate: 0.001, Burst: 2, MaxInFlight: 1}},
+	})
+	users := ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "users"}
+	release, err := l.acquire(context.Background(), users, ops.NopMetrics)
+	require.NoError(t, err)
+	defer release()
+
+	// the table budget rejects the read after it took a read token
+	_, err = l.acquire(context.Background(), users, ops.NopMetrics)
+	assert.Equal(t, ops.ErrRateLimited, err)
+	assert.InDelta(t, 1.0, l.classes[ops.ClassRead].bucket.available(), 0.01)
+
+	// the token is left for a read of another table
+	release, err = l.acquire(context.Background(), ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "orders"}, ops.NopMetrics)
+	require.NoError(t, err)
+	defer release()
+
+	// a budget with no slot left keeps its token too
+	_, err = l.acquire(context.Background(), ops.QueryInfo{Operation: ops.OpInsert, Keyspace: "ks", Table: "orders"}, ops.NopMetrics)
+	assert.Equal(t, ops.ErrRateLimited, err)
+	assert.InDelta(t, 1.0, l.tables["ks.orders"].bucket.available(), 0.01)
+}
@@ -0,0 +1,294 @@
+package cassandradb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetOptions controls ExportParquetWithOptions.
+type ParquetOptions struct {
+	// MaxFileSize rolls the export over to a new file once the current one
+	// has grown to about that many bytes. Zero writes a single file.
+	MaxFileSize int64
+	// RowGroupRows is the maximum number of rows of a row group, defaults
+	// to 10000.
+	RowGroupRows int64
+}
+
+// parquetType is the parquet node of a cql type and the Go type its values
+// are converted to before they are written.
+type parquetType struct {
+	node   parquet.Node
+	goType reflect.Type
+}
+
+var parquetTypes = map[string]parquetType{
+	"text":      {parquet.String(), reflect.TypeOf("")},
+	"varchar":   {parquet.String(), reflect.TypeOf("")},
+	"ascii":     {parquet.String(), reflect.TypeOf("")},
+	"inet":      {parquet.String(), reflect.TypeOf("")},
+	"tinyint":   {parquet.Int(8), reflect.TypeOf(int8(0))},
+	"smallint":  {parquet.Int(16), reflect.TypeOf(int16(0))},
+	"int":       {parquet.Int(32), reflect.TypeOf(int32(0))},
+	"bigint":    {parquet.Int(64), reflect.TypeOf(int64(0))},
+	"counter":   {parquet.Int(64), reflect.TypeOf(int64(0))},
+	"boolean":   {parquet.Leaf(parquet.BooleanType), reflect.TypeOf(false)},
+	"float":     {parquet.Leaf(parquet.FloatType), reflect.TypeOf(float32(0))},
+	"double":    {parquet.Leaf(parquet.DoubleType), reflect.TypeOf(float64(0))},
+	"timestamp": {parquet.Timestamp(parquet.Millisecond), timeType},
+	"uuid":      {parquet.UUID(), reflect.TypeOf([16]byte{})},
+	"timeuuid":  {parquet.UUID(), reflect.TypeOf([16]byte{})},
+	"blob":      {parquet.Leaf(parquet.ByteArrayType), bytesType},
+}
+
+// ExportParquet writes every row of the table to the parquet file at path
+// and returns the files written. See ExportParquetWithOptions.
+func ExportParquet(t *Table, path string) ([]string, error) {
+	return ExportParquetWithOptions(t, path, ParquetOptions{})
+}
+
+// ExportParquetWithOptions writes every row of the table to parquet files.
+// The schema is derived from the data model: sets and lists are repeated
+// fields, maps are MAP and timestamps are TIMESTAMP(MILLIS) adjusted to UTC.
+// The rows are streamed from a scan of the table, a token range at a time,
+// so that a range read again after a failure is written once. With a
+// MaxFileSize the files are named after path with a sequence number before
+// the extension, users-00000.parquet, users-00001.parquet and so on.
+func ExportParquetWithOptions(t *Table, path string, opts ParquetOptions) ([]string, error) {
+	columns, err := exportColumns(t, ops.OpScan)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := newParquetExporter(t, path, columns, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = exportRows(t, func(row interface{}) error {
+		return exp.write(reflect.ValueOf(row))
+	})
+	if closeErr := exp.close(); err == nil {
+		err = closeErr
+	}
+	return exp.files, err
+}
+
+// parquetSchema derives the parquet schema of columns.
+func parquetSchema(t *Table, columns []Entity) (*parquet.Schema, error) {
+	group := parquet.Group{}
+	for _, entity := range columns {
+		node, err := parquetNode(entity)
+		if err != nil {
+			return nil, t.invalidQuery(ops.OpScan, "%v", err)
+		}
+		group[entity.columnName] = node
+	}
+	return parquet.NewSchema(t.Name, group), nil
+}
+
+func parquetNode(entity Entity) (parquet.Node, error) {
+	leaf := func(cqlType string) (parquet.Node, error) {
+		typ, ok := parquetTypes[cqlType]
+		if !ok {
+			return nil, fmt.Errorf("no parquet type for column %s of type %s", entity.columnName, cqlType)
+		}
+		return typ.node, nil
+	}
+	if entity.columnType != "collection" {
+		return leaf(entity.columnType)
+	}
+	val, err := leaf(entity.columnValType)
+	if err != nil {
+		return nil, err
+	}
+	switch entity.columnSubType {
+	case "set", "list":
+		return parquet.Repeated(val), nil
+	case "map":
+		key, err := leaf(entity.columnKeyType)
+		if err != nil {
+			return nil, err
+		}
+		return parquet.Map(key, val), nil
+	}
+	return nil, fmt.Errorf("invalid collection type : %s", entity.columnSubType)
+}
+
+// parquetGoType returns the Go type parquetValue converts the values of
+// entity to.
+func parquetGoType(entity Entity) reflect.Type {
+	if entity.columnType != "collection" {
+		return parquetTypes[entity.columnType].goType
+	}
+	elemType := parquetTypes[entity.columnValType].goType
+	if entity.columnSubType == "map" {
+		return reflect.MapOf(parquetTypes[entity.columnKeyType].goType, elemType)
+	}
+	return reflect.SliceOf(elemType)
+}
+
+// parquetValue converts the field of entity to the Go type written for it.
+func parquetValue(entity Entity, field reflect.Value) (reflect.Value, error) {
+	if entity.encrypt != "" {
+		// the rows are read decrypted, the blob holds the plaintext
+		plain, err := plaintext(field.Interface())
+		return reflect.ValueOf(plain), err
+	}
+	if entity.columnType != "collection" {
+		return convertParquet(entity.columnType, field)
+	}
+	switch entity.columnSubType {
+	case "set", "list":
+		if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+			return reflect.Value{}, fmt.Errorf("%s is not a slice", field.Type())
+		}
+		out := reflect.MakeSlice(parquetGoType(entity), field.Len(), field.Len())
+		for i := 0; i < field.Len(); i++ {
+			v, err := convertParquet(entity.columnValType, field.Index(i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(v)
+		}
+		return out, nil
+	}
+	if field.Kind() != reflect.Map {
+		return reflect.Value{}, fmt.Errorf("%s is not a map", field.Type())
+	}
+	out := reflect.MakeMapWithSize(parquetGoType(entity), field.Len())
+	iter := field.MapRange()
+	for iter.Next() {
+		k, err := convertParquet(entity.columnKeyType, iter.Key())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v, err := convertParquet(entity.columnValType, iter.Value())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetMapIndex(k, v)
+	}
+	return out, nil
+}
+
+func convertParquet(cqlType string, v reflect.Value) (reflect.Value, error) {
+	goType := parquetTypes[cqlType].goType
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return reflect.Zero(goType), nil
+	}
+	if goType == timeType {
+		if t, ok := v.Interface().(time.Time); ok {
+			return reflect.ValueOf(t.UTC()), nil
+		}
+	}
+	if !v.Type().ConvertibleTo(goType) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", v.Type(), cqlType)
+	}
+	return v.Convert(goType), nil
+}
+
+// parquetExporter writes rows to a sequence of parquet files.
+type parquetExporter struct {
+	table   *Table
+	path    string
+	columns []Entity
+	schema  *parquet.Schema
+	opts    ParquetOptions
+	files   []string
+	file    *os.File
+	writer  *parquet.Writer
+	// row is reused for every row written, a struct with a field tagged
+	// with the column name for every column
+	row reflect.Value
+}
+
+func newParquetExporter(t *Table, path string, columns []Entity, opts ParquetOptions) (*parquetExporter, error) {
+	schema, err := parquetSchema(t, columns)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RowGroupRows <= 0 {
+		opts.RowGroupRows = 10000
+	}
+	fields := make([]reflect.StructField, len(columns))
+	for i, entity := range columns {
+		fields[i] = reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: parquetGoType(entity),
+			Tag: reflect.StructTag(`parquet:"` + entity.columnName + `"`)}
+	}
+	exp := &parquetExporter{table: t, path: path, columns: columns, schema: schema, opts: opts,
+		row: reflect.New(reflect.StructOf(fields))}
+	// an empty table still gets a file
+	return exp, exp.open()
+}
+
+// fileName returns the name of the n-th file of the export.
+func (exp *parquetExporter) fileName(n int) string {
+	if exp.opts.MaxFileSize <= 0 {
+		return exp.path
+	}
+	ext := filepath.Ext(exp.path)
+	return fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(exp.path, ext), n, ext)
+}
+
+func (exp *parquetExporter) open() error {
+	name := exp.fileName(len(exp.files))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	exp.files = append(exp.files, name)
+	exp.file = f
+	exp.writer = parquet.NewWriter(f, exp.schema, parquet.MaxRowsPerRowGroup(exp.opts.RowGroupRows),
+		parquet.Compression(&parquet.Snappy))
+	return nil
+}
+
+func (exp *parquetExporter) write(row reflect.Value) error {
+	if exp.writer == nil {
+		if err := exp.open(); err != nil {
+			return err
+		}
+	}
+	for i, entity := range exp.columns {
+		v, err := parquetValue(entity, row.FieldByName(entity.fieldName))
+		if err != nil {
+			return exp.table.invalidQuery(ops.OpScan, "column %s: %v", entity.columnName, err)
+		}
+		exp.row.Elem().Field(i).Set(v)
+	}
+	if err := exp.writer.Write(exp.row.Interface()); err != nil {
+		return err
+	}
+	if exp.opts.MaxFileSize > 0 && exp.writer.Size() >= exp.opts.MaxFileSize {
+		// the next file is opened by the next row
+		return exp.closeFile()
+	}
+	return nil
+}
+
+func (exp *parquetExporter) closeFile() error {
+	err := exp.writer.Close()
+	if closeErr := exp.file.Close(); err == nil {
+		err = closeErr
+	}
+	exp.writer = nil
+	exp.file = nil
+	return err
+}
+
+func (exp *parquetExporter) close() error {
+	if exp.writer == nil {
+		return nil
+	}
+	return exp.closeFile()
+}
@@ -0,0 +1,165 @@
+package cassandradb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportParquetRows writes rows the way ExportParquetWithOptions writes the
+// rows of a scan of a single range.
+func exportParquetRows(t *testing.T, tbl *Table, path string, opts ParquetOptions, rows []exportedEvent) []string {
+	exp, stage := newParquetStage(t, tbl, path, opts)
+	for i := range rows {
+		require.NoError(t, stage.add(rows[i]))
+	}
+	stage.done(TokenRange{}, int64(len(rows)))
+	require.NoError(t, stage.error())
+	require.NoError(t, exp.close())
+	return exp.files
+}
+
+func newParquetStage(t *testing.T, tbl *Table, path string, opts ParquetOptions) (*parquetExporter, *rangeStage) {
+	columns, err := exportColumns(tbl, ops.OpScan)
+	require.NoError(t, err)
+	exp, err := newParquetExporter(tbl, path, columns, opts)
+	require.NoError(t, err)
+	return exp, &rangeStage{write: func(row interface{}) error { return exp.write(reflect.ValueOf(row)) }}
+}
+
+// parquetEvent is exportedEvent as read back from parquet.
+type parquetEvent struct {
+	ID      [16]byte          `parquet:"id"`
+	At      time.Time         `parquet:"at,timestamp(millisecond)"`
+	Name    string            `parquet:"name"`
+	Count   int32             `parquet:"count"`
+	Score   float64           `parquet:"score"`
+	Done    bool              `parquet:"done"`
+	Tags    []string          `parquet:"tags"`
+	Attrs   map[string]string `parquet:"attrs"`
+	Payload []byte            `parquet:"payload"`
+}
+
+func readParquet(t *testing.T, path string) (*parquet.Schema, []parquetEvent) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	st, err := f.Stat()
+	require.NoError(t, err)
+	file, err := parquet.OpenFile(f, st.Size())
+	require.NoError(t, err)
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	var rows []parquetEvent
+	for i := int64(0); i < file.NumRows(); i++ {
+		var row parquetEvent
+		require.NoError(t, reader.Read(&row))
+		rows = append(rows, row)
+	}
+	return file.Schema(), rows
+}
+
+func TestParquetSchema(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	files := exportParquetRows(t, tbl, filepath.Join(t.TempDir(), "events.parquet"), ParquetOptions{}, nil)
+	require.Len(t, files, 1)
+
+	schema, rows := readParquet(t, files[0])
+	assert.Empty(t, rows)
+	text := schema.String()
+	for _, field := range []string{
+		"required fixed_len_byte_array(16) id (UUID);",
+		"required int64 at (TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS));",
+		"required binary name (STRING);",
+		"required int32 count (INT(32,true));",
+		"required double score;",
+		"required boolean done;",
+		"repeated binary tags (STRING);",
+		"required group attrs (MAP) {",
+		"required binary payload;",
+	} {
+		assert.Contains(t, text, field)
+	}
+
+	type unsupported struct {
+		ID    string  `cql:"column_name=id,primary_key=0"`
+		Price float64 `cql:"column_name=price,column_type=decimal"`
+	}
+	bad, _ := newRecordingTable(t, unsupported{})
+	_, err := ExportParquet(bad, filepath.Join(t.TempDir(), "bad.parquet"))
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	events := exportedEvents()
+	files := exportParquetRows(t, tbl, filepath.Join(t.TempDir(), "events.parquet"), ParquetOptions{}, events)
+
+	_, rows := readParquet(t, files[0])
+	require.Len(t, rows, 2)
+	first := rows[0]
+	assert.Equal(t, [16]byte(events[0].ID), first.ID)
+	assert.True(t, events[0].At.Equal(first.At), first.At)
+	assert.Equal(t, "signup, \"web\"", first.Name)
+	assert.EqualValues(t, 3, first.Count)
+	assert.Equal(t, 0.5, first.Score)
+	assert.True(t, first.Done)
+	assert.ElementsMatch(t, []string{"a", "b"}, first.Tags)
+	assert.Equal(t, map[string]string{"k": "v"}, first.Attrs)
+	assert.Equal(t, []byte{1, 2, 3}, first.Payload)
+
+	assert.Equal(t, "login", rows[1].Name)
+	assert.Empty(t, rows[1].Tags)
+}
+
+func TestParquetRollsOverBySize(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	var events []exportedEvent
+	for i := 0; i < 500; i++ {
+		e := exportedEvents()[0]
+		e.Name = strings.Repeat("x", 200)
+		events = append(events, e)
+	}
+	dir := t.TempDir()
+	files := exportParquetRows(t, tbl, filepath.Join(dir, "events.parquet"),
+		ParquetOptions{MaxFileSize: 16 * 1024, RowGroupRows: 50}, events)
+
+	require.Greater(t, len(files), 1)
+	assert.Equal(t, filepath.Join(dir, "events-00000.parquet"), files[0])
+	assert.Equal(t, filepath.Join(dir, "events-00001.parquet"), files[1])
+	total := 0
+	for _, file := range files {
+		_, rows := readParquet(t, file)
+		total += len(rows)
+	}
+	assert.Equal(t, len(events), total)
+}
+
+func TestParquetRetriedRangeWrittenOnce(t *testing.T) {
+	tbl, _ := newRecordingTable(t, exportedEvent{})
+	events := exportedEvents()
+	exp, stage := newParquetStage(t, tbl, filepath.Join(t.TempDir(), "events.parquet"), ParquetOptions{})
+
+	// the range fails after its first row and is read again
+	require.NoError(t, stage.add(events[0]))
+	stage.retry(TokenRange{}, ops.ErrTimeout)
+	for i := range events {
+		require.NoError(t, stage.add(events[i]))
+	}
+	stage.done(TokenRange{}, int64(len(events)))
+	require.NoError(t, exp.close())
+
+	_, rows := readParquet(t, exp.files[0])
+	require.Len(t, rows, 2)
+	assert.Equal(t, events[0].Name, rows[0].Name)
+	assert.Equal(t, events[1].Name, rows[1].Name)
+}
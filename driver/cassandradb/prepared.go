@@ -0,0 +1,365 @@
+package cassandradb
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+// stmtCacheSize is the number of statements a table keeps.
+const stmtCacheSize = 256
+
+// StatementCacheStats reports the use of the statement cache of a table.
+type StatementCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// Size is the number of statements cached
+	Size int
+}
+
+// stmtCache keeps the parameterized statements generated for a table, by
+// operation and column set, least recently used first out. It is shared by
+// the copies of the table.
+type stmtCache struct {
+	sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	hits    uint64
+	misses  uint64
+}
+
+type stmtEntry struct {
+	key  string
+	stmt string
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// statement returns the statement cached under key, it is built and
+// cached on a miss. A nil cache builds every statement.
+func (c *stmtCache) statement(key string, build func() string) (string, bool) {
+	if c == nil {
+		return build(), false
+	}
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(e)
+		return e.Value.(*stmtEntry).stmt, true
+	}
+	c.misses++
+	stmt := build()
+	c.entries[key] = c.order.PushFront(&stmtEntry{key: key, stmt: stmt})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*stmtEntry).key)
+	}
+	return stmt, false
+}
+
+// invalidate drops every cached statement, the statistics are kept.
+func (c *stmtCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *stmtCache) stats() StatementCacheStats {
+	if c == nil {
+		return StatementCacheStats{}
+	}
+	c.Lock()
+	defer c.Unlock()
+	return StatementCacheStats{Hits: c.hits, Misses: c.misses, Size: c.order.Len()}
+}
+
+// StatementCacheStats returns the hits and misses of the statement cache
+// of the table.
+func (t *Table) StatementCacheStats() StatementCacheStats {
+	return t.stmts.stats()
+}
+
+// cachedStatement returns the statement of op cached under key and
+// reports the lookup to the metrics.
+func (t *Table) cachedStatement(op string, key string, build func() string) string {
+	stmt, hit := t.stmts.statement(op+"|"+key, build)
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	t.conn.metricSink().Add(ops.MetricStmtCache, ops.Labels{ops.LabelKeyspace: t.KeySpace,
+		ops.LabelTable: t.Name, ops.LabelOperation: op, ops.LabelResult: result}, 1)
+	return stmt
+}
+
+// insertStatement returns the insert of every column of the table and the
+// values of data to bind to it.
+func (t *Table) insertStatement(data interface{}, ifNotExists bool) (string, []interface{}, error) {
+	row := reflect.Indirect(reflect.ValueOf(data))
+	if row.Kind() != reflect.Struct {
+		return "", nil, t.invalidQuery(ops.OpInsert, "invalid data for insert, struct required")
+	}
+	columns := make([]string, len(t.entities))
+	values := make([]interface{}, len(t.entities))
+	now := autoNow()
+	for i, entity := range t.entities {
+		field := row.FieldByName(entity.fieldName)
+		if !field.IsValid() {
+			return "", nil, t.invalidQuery(ops.OpInsert, "no such field in data :: %s", entity.fieldName)
+		}
+		value, err := autoValue(entity, field, now, true)
+		if err != nil {
+			return "", nil, wrapError(t.queryInfo(ops.OpInsert, ""), err)
+		}
+		columns[i] = entity.columnName
+		values[i] = value.Interface()
+	}
+	if err := t.validateRow(ops.OpInsert, row); err != nil {
+		return "", nil, err
+	}
+	for i, entity := range t.entities {
+		if entity.encrypt == "" {
+			continue
+		}
+		sealed, err := t.encryptValue(ops.OpInsert, entity, values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = sealed
+	}
+
+	key := strings.Join(columns, ",")
+	if ifNotExists {
+		key += "|if_not_exists"
+	}
+	stmt := t.cachedStatement(ops.OpInsert, key, func() string {
+		var buffer bytes.Buffer
+		fmt.Fprintf(&buffer, "INSERT INTO %s.%s (%s) VALUES (?", t.KeySpace, t.Name, strings.Join(columns, ", "))
+		buffer.WriteString(strings.Repeat(", ?", len(columns)-1))
+		buffer.WriteString(")")
+		if ifNotExists {
+			buffer.WriteString(" IF NOT EXISTS")
+		}
+		buffer.WriteString(";")
+		return buffer.String()
+	})
+	return stmt, values, nil
+}
+
+// bindWhere returns the where clause with a bind marker for every value,
+// and the values.
+func (t *Table) bindWhere(op string, whereClause []whc.WhereClauseType) (string, []interface{}, error) {
+	whereClause, err := t.encryptWhere(op, whereClause)
+	if err != nil {
+		return "", nil, err
+	}
+	parts := make([]string, len(whereClause))
+	values := make([]interface{}, len(whereClause))
+	for i, wc := range whereClause {
+		if _, ok := t.entity(wc.ColumnName); !ok {
+			return "", nil, t.invalidQuery(op, "invalid field in where clause :: %s", wc.ColumnName)
+		}
+		parts[i] = wc.ColumnName + " " + wc.RelationType + " ?"
+		values[i] = wc.ColumnValue
+	}
+	return strings.Join(parts, " AND "), values, nil
+}
+
+// readStatement returns the select of columns with bind markers for the
+// where clause values, and the values.
+func (t *Table) readStatement(op string, columns []string, whereClause []whc.WhereClauseType,
+	groupByClause []string, orderByClause map[string]string) (string, []interface{}, error) {
+
+	where, values, err := t.bindWhere(op, whereClause)
+	if err != nil {
+		return "", nil, err
+	}
+	// the order of a map is random, sort it to get one statement per column set
+	var orderBy []string
+	for k, v := range orderByClause {
+		orderBy = append(orderBy, k+" "+v)
+	}
+	sort.Strings(orderBy)
+
+	selectList := strings.Join(columns, ", ")
+	groupBy := strings.Join(groupByClause, " , ")
+	key := selectList + "|" + where + "|" + groupBy + "|" + strings.Join(orderBy, ",")
+	stmt := t.cachedStatement(op, key, func() string {
+		var buffer bytes.Buffer
+		fmt.Fprintf(&buffer, "SELECT %s FROM %s.%s", selectList, t.KeySpace, t.Name)
+		if where != "" {
+			buffer.WriteString(" WHERE ")
+			buffer.WriteString(where)
+		}
+		if groupBy != "" {
+			buffer.WriteString(" GROUP BY ")
+			buffer.WriteString(groupBy)
+		}
+		if len(orderBy) > 0 {
+			buffer.WriteString(" ORDER BY ")
+			buffer.WriteString(strings.Join(orderBy, " , "))
+		}
+		buffer.WriteString(";")
+		return buffer.String()
+	})
+	return stmt, values, nil
+}
+
+// updateStatement returns the update of the columns of updateMap with a
+// bind marker for every value, and the values.
+func (t *Table) updateStatement(updateMap, updateParm map[string]interface{},
+	whereClause []whc.WhereClauseType) (string, []interface{}, error) {
+
+	var values []interface{}
+
+	// the order of a map is random, sort it to get one statement per column set
+	params := make([]string, 0, len(updateParm))
+	for k := range updateParm {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for i, k := range params {
+		values = append(values, updateParm[k])
+		params[i] = k + " ?"
+	}
+
+	columns := make([]string, 0, len(updateMap))
+	for k := range updateMap {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	sets := make([]string, len(columns))
+	for i, k := range columns {
+		set, v, err := t.updateSet(k, updateMap[k])
+		if err != nil {
+			return "", nil, err
+		}
+		sets[i] = set
+		values = append(values, v)
+	}
+
+	if len(whereClause) == 0 {
+		return "", nil, t.invalidQuery(ops.OpUpdate, "no where clause in update statement")
+	}
+	where, whereValues, err := t.bindWhere(ops.OpUpdate, whereClause)
+	if err != nil {
+		return "", nil, err
+	}
+	values = append(values, whereValues...)
+
+	using := strings.Join(params, " AND ")
+	set := strings.Join(sets, " , ")
+	stmt := t.cachedStatement(ops.OpUpdate, using+"|"+set+"|"+where, func() string {
+		var buffer bytes.Buffer
+		fmt.Fprintf(&buffer, "UPDATE %s.%s ", t.KeySpace, t.Name)
+		if using != "" {
+			buffer.WriteString("USING ")
+			buffer.WriteString(using)
+			buffer.WriteString(" ")
+		}
+		fmt.Fprintf(&buffer, "SET %s WHERE %s;", set, where)
+		return buffer.String()
+	})
+	return stmt, values, nil
+}
+
+// deleteStatement returns the delete of columns, or of the rows when there
+// are none, with bind markers for the where clause values, and the values.
+// The values of an in relation are bound as one list.
+func (t *Table) deleteStatement(columns []string, whereClause []whc.WhereClauseType) (string, []interface{}, error) {
+	if len(whereClause) == 0 {
+		return "", nil, t.invalidQuery(ops.OpDelete, "cannot delete without where clause: %s", t.Name)
+	}
+	for _, wc := range whereClause {
+		if wc.RelationType != "in" {
+			continue
+		}
+		s := reflect.ValueOf(wc.ColumnValue)
+		if s.Kind() != reflect.Slice {
+			return "", nil, t.invalidQuery(ops.OpDelete, "invalid datatype in delete whereClause , should be an array when using \"in\" : %s", wc.ColumnName)
+		}
+		if s.Len() == 0 {
+			return "", nil, t.invalidQuery(ops.OpDelete, "invalid where clause in delete query, no values for \"in\" operator : %s", wc.ColumnName)
+		}
+	}
+	where, values, err := t.bindWhere(ops.OpDelete, whereClause)
+	if err != nil {
+		return "", nil, err
+	}
+
+	selectList := strings.Join(columns, " , ")
+	stmt := t.cachedStatement(ops.OpDelete, selectList+"|"+where, func() string {
+		var buffer bytes.Buffer
+		buffer.WriteString("DELETE ")
+		if selectList != "" {
+			buffer.WriteString(selectList)
+			buffer.WriteString(" ")
+		}
+		fmt.Fprintf(&buffer, "FROM %s.%s WHERE %s;", t.KeySpace, t.Name, where)
+		return buffer.String()
+	})
+	return stmt, values, nil
+}
+
+// updateSet returns the assignment of column k and the value to bind to
+// it. Counters take a two element slice of the operator and the delta,
+// collections one of "all", "add" or "remove" and the elements.
+func (t *Table) updateSet(k string, v interface{}) (string, interface{}, error) {
+	entity, ok := t.entity(k)
+	if !ok {
+		return "", nil, t.invalidQuery(ops.OpUpdate, "invalid field in update :: %s", k)
+	}
+	switch entity.columnType {
+	case "counter":
+		s := reflect.ValueOf(v)
+		if s.Kind() != reflect.Slice || s.Len() != 2 {
+			return "", nil, t.invalidQuery(ops.OpUpdate, "invalid update values for counter field : %s", k)
+		}
+		operator := fmt.Sprintf("%v", s.Index(0).Interface())
+		if operator != "+" && operator != "-" {
+			return "", nil, t.invalidQuery(ops.OpUpdate, "invalid operator for counter field : %s", k)
+		}
+		return k + " = " + k + " " + operator + " ?", s.Index(1).Interface(), nil
+	case "collection":
+		s := reflect.ValueOf(v)
+		if s.Kind() != reflect.Slice {
+			return "", nil, t.invalidQuery(ops.OpUpdate, "invalid update values for set or list field : %s", k)
+		}
+		if s.Len() < 2 {
+			return "", nil, t.invalidQuery(ops.OpUpdate, "too few values for set field : %s", k)
+		}
+		switch fmt.Sprintf("%v", s.Index(0).Interface()) {
+		case "all":
+			return k + " = ?", s.Index(1).Interface(), nil
+		case "add":
+			return k + " = " + k + " + ?", s.Index(1).Interface(), nil
+		case "remove":
+			return k + " = " + k + " - ?", s.Index(1).Interface(), nil
+		}
+		return "", nil, t.invalidQuery(ops.OpUpdate, "invalid update operation for collection field : %s", k)
+	}
+	if entity.encrypt != "" {
+		sealed, err := t.encryptValue(ops.OpUpdate, entity, v)
+		if err != nil {
+			return "", nil, err
+		}
+		return k + " = ?", sealed, nil
+	}
+	return k + " = ?", v, nil
+}
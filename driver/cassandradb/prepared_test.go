@@ -0,0 +1,167 @@
+package cassandradb
+
+import (
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertStatementIsCached(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	row := &pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01", Hour: 10, Views: 3, Score: 0.5, Page: "/"}
+
+	assert.ErrorIs(t, tbl.Insert(row), ops.ErrUnavailable)
+	row.Views = 4
+	assert.ErrorIs(t, tbl.WithContext(nil).Insert(*row), ops.ErrUnavailable)
+
+	require.Len(t, *stmts, 2)
+	assert.Equal(t, "INSERT INTO ks.views (tenant, region, day, hour, views, score, page) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?);", (*stmts)[0])
+	assert.Equal(t, (*stmts)[0], (*stmts)[1])
+	assert.Equal(t, StatementCacheStats{Hits: 1, Misses: 1, Size: 1}, tbl.StatementCacheStats())
+
+	_, values, err := tbl.insertStatement(row, false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"acme", "eu", "2024-05-01", 10, 4, 0.5, "/"}, values)
+
+	stmt, _, err := tbl.insertStatement(row, true)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO ks.views (tenant, region, day, hour, views, score, page) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS;", stmt)
+}
+
+func TestUpdateStatementIsStable(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"},
+		{ColumnName: "region", RelationType: "=", ColumnValue: "eu"}}
+
+	for i := 0; i < 5; i++ {
+		err := tbl.UpdateFields(map[string]interface{}{"views": i, "page": "/p", "score": 1.5},
+			map[string]interface{}{"TTL": 60}, where)
+		assert.ErrorIs(t, err, ops.ErrUnavailable)
+	}
+	require.Len(t, *stmts, 5)
+	for _, stmt := range *stmts {
+		assert.Equal(t, "UPDATE ks.views USING TTL ? SET page = ? , score = ? , views = ? "+
+			"WHERE tenant = ? AND region = ?;", stmt)
+	}
+	assert.Equal(t, StatementCacheStats{Hits: 4, Misses: 1, Size: 1}, tbl.StatementCacheStats())
+
+	_, values, err := tbl.updateStatement(map[string]interface{}{"views": 7, "page": "/p"}, nil, where)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"/p", 7, "acme", "eu"}, values)
+
+	assert.ErrorIs(t, tbl.UpdateFields(map[string]interface{}{"nope": 1}, nil, where), ops.ErrInvalidQuery)
+	assert.ErrorIs(t, tbl.UpdateFields(map[string]interface{}{"views": 1}, nil, nil), ops.ErrInvalidQuery)
+	assert.ErrorIs(t, tbl.UpdateFields(map[string]interface{}{}, nil, where), ops.ErrInvalidQuery)
+}
+
+func TestUpdateCollectionStatement(t *testing.T) {
+	tbl := newProjectedTable(t)
+	tbl.stmts = newStmtCache(stmtCacheSize)
+	where := []whc.WhereClauseType{{ColumnName: "id", RelationType: "=", ColumnValue: "u1"}}
+	ssoids := map[string]string{"google": "g1"}
+
+	stmt, values, err := tbl.updateStatement(map[string]interface{}{"ssoids": []interface{}{"add", ssoids}}, nil, where)
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE ks.users SET ssoids = ssoids + ? WHERE id = ?;", stmt)
+	assert.Equal(t, []interface{}{ssoids, "u1"}, values)
+
+	stmt, _, err = tbl.updateStatement(map[string]interface{}{"ssoids": []interface{}{"all", ssoids}}, nil, where)
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE ks.users SET ssoids = ? WHERE id = ?;", stmt)
+
+	_, _, err = tbl.updateStatement(map[string]interface{}{"ssoids": []interface{}{"merge", ssoids}}, nil, where)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestReadStatementIsCachedPerColumnSet(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"},
+		{ColumnName: "region", RelationType: "in", ColumnValue: []string{"eu", "us"}}}
+
+	_, err := tbl.Read(where, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	var row pageView
+	assert.ErrorIs(t, tbl.ReadAndBind(&row, where, nil, nil), ops.ErrUnavailable)
+	_, err = tbl.Select("views").Read(where, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+
+	require.Len(t, *stmts, 3)
+	assert.Equal(t, "SELECT tenant, region, day, hour, views, score, page FROM ks.views "+
+		"WHERE tenant = ? AND region in ?;", (*stmts)[0])
+	assert.Equal(t, (*stmts)[0], (*stmts)[1])
+	assert.Equal(t, "SELECT views FROM ks.views WHERE tenant = ? AND region in ?;", (*stmts)[2])
+	assert.Equal(t, StatementCacheStats{Hits: 1, Misses: 2, Size: 2}, tbl.StatementCacheStats())
+
+	_, err = tbl.Read([]whc.WhereClauseType{{ColumnName: "nope", RelationType: "=", ColumnValue: 1}}, nil, nil)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestDeleteStatementIsCached(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	for _, tenant := range []string{"acme", "globex"} {
+		where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: tenant},
+			{ColumnName: "region", RelationType: "in", ColumnValue: []string{"eu", "us"}}}
+		assert.ErrorIs(t, tbl.Delete(nil, where), ops.ErrUnavailable)
+	}
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"}}
+	assert.ErrorIs(t, tbl.Delete([]string{"page", "score"}, where), ops.ErrUnavailable)
+
+	require.Len(t, *stmts, 3)
+	assert.Equal(t, "DELETE FROM ks.views WHERE tenant = ? AND region in ?;", (*stmts)[0])
+	assert.Equal(t, (*stmts)[0], (*stmts)[1])
+	assert.Equal(t, "DELETE page , score FROM ks.views WHERE tenant = ?;", (*stmts)[2])
+	assert.Equal(t, StatementCacheStats{Hits: 1, Misses: 2, Size: 2}, tbl.StatementCacheStats())
+
+	_, values, err := tbl.deleteStatement(nil, []whc.WhereClauseType{
+		{ColumnName: "region", RelationType: "in", ColumnValue: []string{"eu", "us"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]string{"eu", "us"}}, values)
+
+	for _, where := range [][]whc.WhereClauseType{
+		nil,
+		{{ColumnName: "nope", RelationType: "=", ColumnValue: 1}},
+		{{ColumnName: "region", RelationType: "in", ColumnValue: "eu"}},
+		{{ColumnName: "region", RelationType: "in", ColumnValue: []string{}}},
+	} {
+		assert.ErrorIs(t, tbl.Delete(nil, where), ops.ErrInvalidQuery)
+	}
+	assert.Len(t, *stmts, 3)
+}
+
+func TestAlterTableInvalidatesStatements(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	row := &pageView{Tenant: "acme", Region: "eu", Day: "2024-05-01"}
+	assert.ErrorIs(t, tbl.Insert(row), ops.ErrUnavailable)
+	require.Equal(t, 1, tbl.StatementCacheStats().Size)
+
+	require.NoError(t, tbl.AlterTable(pageView{}))
+	assert.Equal(t, StatementCacheStats{Hits: 0, Misses: 1, Size: 0}, tbl.StatementCacheStats())
+	// nothing is run against the table
+	assert.Len(t, *stmts, 1)
+
+	// the statement is built again
+	assert.ErrorIs(t, tbl.Insert(row), ops.ErrUnavailable)
+	assert.Equal(t, StatementCacheStats{Hits: 0, Misses: 2, Size: 1}, tbl.StatementCacheStats())
+	assert.Equal(t, (*stmts)[0], (*stmts)[1])
+}
+
+func TestStatementCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newStmtCache(2)
+	build := func(stmt string) func() string { return func() string { return stmt } }
+	c.statement("a", build("A"))
+	c.statement("b", build("B"))
+	_, hit := c.statement("a", build("A"))
+	assert.True(t, hit)
+	c.statement("c", build("C"))
+
+	_, hit = c.statement("b", build("B"))
+	assert.False(t, hit)
+	_, hit = c.statement("c", build("C"))
+	assert.True(t, hit)
+	assert.Equal(t, StatementCacheStats{Hits: 2, Misses: 4, Size: 2}, c.stats())
+}
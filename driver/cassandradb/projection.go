@@ -0,0 +1,222 @@
+package cassandradb
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/meooio/goava/ops"
+)
+
+type columnKind int
+
+const (
+	plainColumn columnKind = iota
+	writeTimeColumn
+	ttlColumn
+)
+
+// projectedColumn is a resolved entry of a column projection.
+type projectedColumn struct {
+	kind   columnKind
+	entity Entity
+	// expr is the expression in the select list, key the name of the
+	// column in the result set
+	expr string
+	key  string
+}
+
+var fieldMaskType = reflect.TypeOf(ops.FieldMask{})
+
+// Select returns a copy of the table whose Read, List and ReadAndBind
+// select only columns. Fields of the model that are not selected are left
+// zero. Columns may include the pseudo-columns built by ops.WriteTime and
+// ops.TTL, whose values are reported through the ops.FieldMask of the model.
+// Select without columns selects every column again.
+func (t *Table) Select(columns ...string) *Table {
+	c := t.WithContext(t.ctx)
+	if len(columns) > 0 {
+		c.columns = append([]string{}, columns...)
+	} else {
+		c.columns = nil
+	}
+	return c
+}
+
+// projection resolves the selected columns against the entities of the
+// table. Without a projection every column is selected.
+func (t *Table) projection(op string) ([]projectedColumn, error) {
+	if len(t.columns) == 0 {
+		cols := make([]projectedColumn, len(t.entities))
+		for i, entity := range t.entities {
+			cols[i] = projectedColumn{kind: plainColumn, entity: entity, expr: entity.columnName, key: entity.columnName}
+		}
+		return cols, nil
+	}
+
+	cols := make([]projectedColumn, 0, len(t.columns))
+	for _, c := range t.columns {
+		kind, name := parseProjectedColumn(c)
+		entity, ok := t.entity(name)
+		if !ok {
+			return nil, t.invalidQuery(op, "invalid column in projection :: %s", c)
+		}
+		col := projectedColumn{kind: kind, entity: entity}
+		switch kind {
+		case plainColumn:
+			col.expr = entity.columnName
+		case writeTimeColumn:
+			col.expr = "WRITETIME(" + entity.columnName + ")"
+		case ttlColumn:
+			col.expr = "TTL(" + entity.columnName + ")"
+		}
+		if kind != plainColumn && (entity.primaryKey || entity.clusteringKey) {
+			return nil, t.invalidQuery(op, "cannot select %s of key column :: %s", c, name)
+		}
+		col.key = strings.ToLower(col.expr)
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// parseProjectedColumn splits WRITETIME(col) and TTL(col) into their kind
+// and column name.
+func parseProjectedColumn(c string) (columnKind, string) {
+	c = strings.TrimSpace(c)
+	upper := strings.ToUpper(c)
+	if strings.HasSuffix(upper, ")") {
+		for prefix, kind := range map[string]columnKind{"WRITETIME(": writeTimeColumn, "TTL(": ttlColumn} {
+			if strings.HasPrefix(upper, prefix) {
+				return kind, strings.ToLower(strings.TrimSpace(c[len(prefix) : len(c)-1]))
+			}
+		}
+	}
+	return plainColumn, strings.ToLower(c)
+}
+
+func (t *Table) entity(column string) (Entity, bool) {
+	for _, entity := range t.entities {
+		if entity.columnName == column {
+			return entity, true
+		}
+	}
+	return Entity{}, false
+}
+
+func selectList(cols []projectedColumn) []string {
+	exprs := make([]string, len(cols))
+	for i, col := range cols {
+		exprs[i] = col.expr
+	}
+	return exprs
+}
+
+// scanTargets returns the scan destinations of cols in row, a struct value
+// of the data model. Pseudo-columns are scanned into values that are later
+// copied into the field mask of the row by setFieldMask, encrypted columns
+// into blobs decrypted by decryptTargets.
+func (t *Table) scanTargets(row reflect.Value, cols []projectedColumn) []interface{} {
+	targets := make([]interface{}, len(cols))
+	for i, col := range cols {
+		switch col.kind {
+		case writeTimeColumn:
+			targets[i] = new(int64)
+		case ttlColumn:
+			targets[i] = new(int)
+		default:
+			field := row.FieldByName(col.entity.fieldName)
+			if !field.IsValid() || !field.CanSet() {
+				t.logField("cannot set field value", col.entity.fieldName)
+				targets[i] = new(interface{})
+				continue
+			}
+			if col.entity.encrypt != "" {
+				// decrypted into the field by decryptTargets
+				targets[i] = new([]byte)
+				continue
+			}
+			targets[i] = field.Addr().Interface()
+		}
+	}
+	return targets
+}
+
+// setFieldMask fills the field mask of row, if the data model has one, from
+// the values read for cols. values holds the scanned values by result column.
+func setFieldMask(row reflect.Value, cols []projectedColumn, values func(i int, col projectedColumn) interface{}) {
+	field, ok := fieldMaskOf(row)
+	if !ok {
+		return
+	}
+	mask := &ops.FieldMask{
+		Loaded:     make(map[string]bool),
+		WriteTimes: make(map[string]time.Time),
+		TTLs:       make(map[string]time.Duration),
+	}
+	for i, col := range cols {
+		name := col.entity.columnName
+		switch col.kind {
+		case plainColumn:
+			mask.Loaded[name] = true
+		case writeTimeColumn:
+			if us := toInt64(values(i, col)); us != 0 {
+				mask.WriteTimes[name] = time.UnixMicro(us)
+			}
+		case ttlColumn:
+			if s := toInt64(values(i, col)); s != 0 {
+				mask.TTLs[name] = time.Duration(s) * time.Second
+			}
+		}
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(mask))
+	} else {
+		field.Set(reflect.ValueOf(*mask))
+	}
+}
+
+// fieldMaskOf returns the untagged field of type ops.FieldMask or
+// *ops.FieldMask of row.
+func fieldMaskOf(row reflect.Value) (reflect.Value, bool) {
+	typ := row.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Tag.Get(tagName) != "" || !f.IsExported() {
+			continue
+		}
+		if f.Type == fieldMaskType || f.Type == reflect.PtrTo(fieldMaskType) {
+			return row.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case *int64:
+		return *n
+	case *int:
+		return int64(*n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	}
+	return 0
+}
+
+// scannedValue returns the value scanned into targets for column i.
+func scannedValue(targets []interface{}) func(int, projectedColumn) interface{} {
+	return func(i int, _ projectedColumn) interface{} {
+		return targets[i]
+	}
+}
+
+// mappedValue returns the value of a column in a row read with MapScan.
+func mappedValue(row map[string]interface{}) func(int, projectedColumn) interface{} {
+	return func(_ int, col projectedColumn) interface{} {
+		return row[col.key]
+	}
+}
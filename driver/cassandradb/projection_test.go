@@ -0,0 +1,85 @@
+package cassandradb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type projectedUser struct {
+	ID     string            `cql:"column_name=id,primary_key=0"`
+	Name   string            `cql:"column_name=name"`
+	SSOIDs map[string]string `cql:"column_name=ssoids,column_type=collection,column_subtype=map,column_keytype=text,column_valuetype=text"`
+	Mask   *ops.FieldMask
+}
+
+func newProjectedTable(t *testing.T) *Table {
+	entities, err := CreateEntity(projectedUser{})
+	require.NoError(t, err)
+	return &Table{Name: "users", KeySpace: "ks", entities: entities, dataModel: projectedUser{}, conn: newConn(nil)}
+}
+
+func TestProjectionStatement(t *testing.T) {
+	tbl := newProjectedTable(t).Select("name", ops.WriteTime("name"), "ttl(Name)")
+	cols, err := tbl.projection(ops.OpRead)
+	require.NoError(t, err)
+
+	where := []whc.WhereClauseType{{ColumnName: "id", RelationType: "=", ColumnValue: "u1"}}
+	buffer, _ := getReadQueryString(tbl.entities, selectList(cols), tbl.KeySpace, tbl.Name, where, nil, nil)
+	assert.Equal(t, "SELECT name, WRITETIME(name), TTL(name) FROM ks.users WHERE id = 'u1';", buffer.String())
+
+	all, err := tbl.Select().projection(ops.OpRead)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "ssoids"}, selectList(all))
+}
+
+func TestProjectionRejectsInvalidColumns(t *testing.T) {
+	tbl := newProjectedTable(t)
+	_, err := tbl.Select("nope").projection(ops.OpList)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+
+	_, err = tbl.Select(ops.WriteTime("id")).projection(ops.OpList)
+	assert.ErrorIs(t, err, ops.ErrInvalidQuery)
+}
+
+func TestProjectionFieldMask(t *testing.T) {
+	tbl := newProjectedTable(t).Select("name", ops.WriteTime("name"), ops.TTL("name"))
+	cols, err := tbl.projection(ops.OpRead)
+	require.NoError(t, err)
+
+	var user projectedUser
+	row := reflect.ValueOf(&user).Elem()
+	targets := tbl.scanTargets(row, cols)
+	*targets[0].(*string) = "bob"
+	written := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	*targets[1].(*int64) = written.UnixMicro()
+	*targets[2].(*int) = 60
+	setFieldMask(row, cols, scannedValue(targets))
+
+	assert.Equal(t, "bob", user.Name)
+	assert.Nil(t, user.SSOIDs)
+	require.NotNil(t, user.Mask)
+	assert.True(t, user.Mask.IsLoaded("name"))
+	assert.False(t, user.Mask.IsLoaded("ssoids"))
+	wt, ok := user.Mask.WriteTime("name")
+	assert.True(t, ok)
+	assert.True(t, written.Equal(wt))
+	ttl, ok := user.Mask.TTL("name")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, ttl)
+
+	// rows read with MapScan carry the pseudo-columns under their result name
+	result := map[string]interface{}{"name": "amy", "writetime(name)": int64(1), "ttl(name)": 0}
+	listed, err := tbl.rowFromMap(ops.OpList, result)
+	require.NoError(t, err)
+	setFieldMask(listed, cols, mappedValue(result))
+	got := listed.Interface().(projectedUser)
+	assert.Equal(t, "amy", got.Name)
+	_, ok = got.Mask.TTL("name")
+	assert.False(t, ok)
+}
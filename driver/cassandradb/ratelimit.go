@@ -0,0 +1,99 @@
+package cassandradb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket rate limiter refilled at rate tokens per
+// second up to burst tokens.
+type tokenBucket struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now(), now: time.Now}
+}
+
+// reserve takes a token and returns how long the caller has to wait before
+// it may use it. The token is only taken when the wait is within max.
+func (b *tokenBucket) reserve(max time.Duration) (time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > max {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// allow takes a token if one is available right away.
+func (b *tokenBucket) allow() bool {
+	_, ok := b.reserve(0)
+	return ok
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	max := time.Duration(1<<63 - 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		max = time.Until(deadline)
+	}
+	d, ok := b.reserve(max)
+	if !ok {
+		return context.DeadlineExceeded
+	}
+	if d == 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// cancel returns a token taken by a wait that was given up.
+func (b *tokenBucket) cancel() {
+	b.Lock()
+	defer b.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// available returns the tokens left in the bucket.
+func (b *tokenBucket) available() float64 {
+	b.Lock()
+	defer b.Unlock()
+	tokens := b.tokens + b.now().Sub(b.last).Seconds()*b.rate
+	if tokens > b.burst {
+		tokens = b.burst
+	}
+	return tokens
+}
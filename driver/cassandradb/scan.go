@@ -0,0 +1,359 @@
+package cassandradb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+var _ ops.Scanner = (*Table)(nil)
+
+const murmur3Partitioner = "org.apache.cassandra.dht.Murmur3Partitioner"
+
+const (
+	localTokensStmt = `SELECT partitioner, tokens FROM system.local`
+	peerTokensStmt  = `SELECT tokens FROM system.peers`
+)
+
+// TokenRange is a range of the Murmur3 token ring, exclusive of Start and
+// inclusive of End.
+type TokenRange struct {
+	Start int64
+	End   int64
+}
+
+func (r TokenRange) String() string {
+	return fmt.Sprintf("(%d,%d]", r.Start, r.End)
+}
+
+// ScanOptions controls a parallel table scan.
+type ScanOptions struct {
+	// Parallelism is the number of ranges read at the same time, 1 if unset.
+	Parallelism int
+	// SplitsPerWorker is the number of ranges the ring is split into per
+	// worker, so that a slow range does not hold up the scan. Defaults to 4.
+	SplitsPerWorker int
+	// Retries is the number of times a failed range is read again, and
+	// RetryBackoff the pause before the first retry, doubled on every retry.
+	Retries      int
+	RetryBackoff time.Duration
+	// PageSize is the number of rows fetched per page, the driver default
+	// if unset.
+	PageSize int
+	// Ranges restricts the scan to the given ranges instead of the ring
+	// split from the token metadata of the cluster.
+	Ranges []TokenRange
+	// Progress is called after every range with the state of the scan.
+	Progress func(ScanProgress)
+	// RangeDone is called after every range that was read completely.
+	RangeDone func(r TokenRange, rows int64)
+	// RangeRetry is called before a failed range is read again from its
+	// start, with the error of the failed attempt. The rows of that attempt
+	// have been passed to fn already and are delivered again.
+	RangeRetry func(r TokenRange, err error)
+}
+
+// ScanProgress is the state of a scan.
+type ScanProgress struct {
+	Ranges       int
+	RangesDone   int
+	RangesFailed int
+	Rows         int64
+	Elapsed      time.Duration
+}
+
+// ScanError reports the ranges a scan could not read.
+type ScanError struct {
+	Ranges []TokenRange
+	Errs   []error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("scan: %d token ranges failed, first %v: %v", len(e.Ranges), e.Ranges[0], e.Errs[0])
+}
+
+func (e *ScanError) Unwrap() []error {
+	return e.Errs
+}
+
+// Scan reads the whole table in token ranges with parallelism workers and
+// calls fn with every row, decoded into the data model. fn is called from
+// several goroutines. A failed range is retried from its start, so rows may
+// be delivered more than once. An error returned by fn stops the scan.
+func (t *Table) Scan(parallelism int, fn func(row interface{}) error) error {
+	return t.ScanWithOptions(ScanOptions{Parallelism: parallelism, Retries: 3, RetryBackoff: 100 * time.Millisecond}, fn)
+}
+
+// ScanWithOptions is Scan with control over the ranges, retries and
+// progress reporting.
+func (t *Table) ScanWithOptions(opts ScanOptions, fn func(row interface{}) error) error {
+	return t.scan(opts, func(ctx context.Context, row interface{}) error { return fn(row) })
+}
+
+// scan runs the scan of ScanWithOptions, fn gets the context of the scan,
+// which is cancelled once the scan stops.
+func (t *Table) scan(opts ScanOptions, fn func(ctx context.Context, row interface{}) error) error {
+	if opts.Parallelism < 1 {
+		opts.Parallelism = 1
+	}
+	if opts.SplitsPerWorker < 1 {
+		opts.SplitsPerWorker = 4
+	}
+	cols, err := t.projection(ops.OpScan)
+	if err != nil {
+		return err
+	}
+	pks := keyColumns(t.entities, true)
+	if len(pks) == 0 {
+		return t.invalidQuery(ops.OpScan, "table has no partition key")
+	}
+
+	ranges := opts.Ranges
+	if ranges == nil {
+		tokens, err := t.ringTokens()
+		if err != nil {
+			return err
+		}
+		ranges = splitRing(tokens, opts.Parallelism*opts.SplitsPerWorker)
+	}
+
+	token := "token(" + strings.Join(pks, ",") + ")"
+	buffer, _ := getReadQueryString(t.entities, selectList(cols), t.KeySpace, t.Name, nil, nil, nil)
+	stmt := strings.TrimSpace(strings.TrimSuffix(buffer.String(), ";")) +
+		" WHERE " + token + " > ? AND " + token + " <= ?;"
+
+	ctx, cancel := context.WithCancel(t.context())
+	defer cancel()
+	scan := &tableScan{t: t, opts: opts, cols: cols, stmt: stmt, fn: fn, start: time.Now(),
+		progress: ScanProgress{Ranges: len(ranges)}}
+
+	work := make(chan TokenRange)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				scan.readRange(ctx, cancel, r)
+			}
+		}()
+	}
+feed:
+	for _, r := range ranges {
+		select {
+		case work <- r:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if scan.fnErr != nil {
+		return scan.fnErr
+	}
+	if len(scan.failed.Ranges) > 0 {
+		return &scan.failed
+	}
+	return t.context().Err()
+}
+
+type tableScan struct {
+	t     *Table
+	opts  ScanOptions
+	cols  []projectedColumn
+	stmt  string
+	fn    func(ctx context.Context, row interface{}) error
+	start time.Time
+
+	sync.Mutex
+	progress ScanProgress
+	failed   ScanError
+	fnErr    error
+}
+
+// readRange reads r, retrying it on failure, and reports the outcome.
+func (s *tableScan) readRange(ctx context.Context, cancel context.CancelFunc, r TokenRange) {
+	var rows int64
+	var err error
+	backoff := s.opts.RetryBackoff
+	for attempt := 0; attempt <= s.opts.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if s.opts.RangeRetry != nil {
+				s.opts.RangeRetry(r, err)
+			}
+		}
+		var fnErr error
+		rows, fnErr, err = s.query(ctx, r, attempt+1)
+		if fnErr != nil {
+			s.Lock()
+			if s.fnErr == nil {
+				s.fnErr = fnErr
+			}
+			s.Unlock()
+			cancel()
+			return
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	s.Lock()
+	if err != nil {
+		s.progress.RangesFailed++
+		s.failed.Ranges = append(s.failed.Ranges, r)
+		s.failed.Errs = append(s.failed.Errs, err)
+	} else {
+		s.progress.RangesDone++
+	}
+	s.progress.Rows += rows
+	s.progress.Elapsed = time.Since(s.start)
+	progress := s.progress
+	s.Unlock()
+
+	if err == nil && s.opts.RangeDone != nil {
+		s.opts.RangeDone(r, rows)
+	}
+	if s.opts.Progress != nil {
+		s.opts.Progress(progress)
+	}
+}
+
+// query reads the rows of r and passes them to fn. It returns the error of
+// fn separately from the error of the statement.
+func (s *tableScan) query(ctx context.Context, r TokenRange, attempt int) (int64, error, error) {
+	var rows int64
+	var fnErr error
+	info := s.t.queryInfo(ops.OpScan, s.stmt)
+	info.Attempt = attempt
+	err := s.t.conn.do(ctx, info, []interface{}{r.Start, r.End}, func(q *gocql.Query) (int, error) {
+		if s.opts.PageSize > 0 {
+			q = q.PageSize(s.opts.PageSize)
+		}
+		iter := q.Iter()
+		result := make(map[string]interface{})
+		for iter.MapScan(result) {
+			row, err := s.t.rowFromMap(ops.OpScan, result)
+			if err != nil {
+				// a row that cannot be decrypted is not retried
+				fnErr = err
+				break
+			}
+			setFieldMask(row, s.cols, mappedValue(result))
+			if fnErr = s.fn(ctx, row.Interface()); fnErr != nil {
+				break
+			}
+			rows++
+			result = make(map[string]interface{})
+		}
+		return int(rows), iter.Close()
+	})
+	return rows, fnErr, err
+}
+
+// ringTokens returns the tokens of every node of the cluster.
+func (t *Table) ringTokens() ([]int64, error) {
+	ctx := t.context()
+	var partitioner string
+	var tokens []string
+	err := t.conn.scan(ctx, ops.QueryInfo{Operation: ops.OpScan, Keyspace: "system", Table: "local",
+		Statement: localTokensStmt}, nil, &partitioner, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if partitioner != murmur3Partitioner {
+		return nil, ops.NewOpError(ops.OpScan, t.KeySpace, t.Name, ops.ErrNoSupport,
+			"token range scan needs the Murmur3 partitioner, cluster uses %s", partitioner)
+	}
+	var peerTokens []string
+	err = t.conn.iter(ctx, ops.QueryInfo{Operation: ops.OpScan, Keyspace: "system", Table: "peers",
+		Statement: peerTokensStmt}, nil, func(iter *gocql.Iter) bool {
+		var nodeTokens []string
+		if !iter.Scan(&nodeTokens) {
+			return false
+		}
+		peerTokens = append(peerTokens, nodeTokens...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ring := make([]int64, 0, len(tokens)+len(peerTokens))
+	for _, tok := range append(tokens, peerTokens...) {
+		n, err := strconv.ParseInt(tok, 10, 64)
+		if err != nil {
+			return nil, ops.NewOpError(ops.OpScan, t.KeySpace, t.Name, ops.ErrSchemaMismatch, "invalid token %q", tok)
+		}
+		ring = append(ring, n)
+	}
+	return ring, nil
+}
+
+// splitRing returns ranges covering the whole token ring, split at tokens
+// and further split evenly until there are at least n ranges.
+func splitRing(tokens []int64, n int) []TokenRange {
+	tokens = append([]int64{}, tokens...)
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+
+	// ranges between the node tokens, the range wrapping around the end of
+	// the ring is split at its end. math.MinInt64 is never the token of a row.
+	bounds := []int64{math.MinInt64}
+	for _, tok := range tokens {
+		if tok != bounds[len(bounds)-1] {
+			bounds = append(bounds, tok)
+		}
+	}
+	if bounds[len(bounds)-1] != math.MaxInt64 {
+		bounds = append(bounds, math.MaxInt64)
+	}
+	var ranges []TokenRange
+	for i := 1; i < len(bounds); i++ {
+		ranges = append(ranges, TokenRange{Start: bounds[i-1], End: bounds[i]})
+	}
+	if len(ranges) >= n {
+		return ranges
+	}
+
+	perRange := (n + len(ranges) - 1) / len(ranges)
+	split := make([]TokenRange, 0, perRange*len(ranges))
+	for _, r := range ranges {
+		split = append(split, r.split(perRange)...)
+	}
+	return split
+}
+
+// split divides r into up to n ranges of about the same width.
+func (r TokenRange) split(n int) []TokenRange {
+	width := uint64(r.End) - uint64(r.Start)
+	if n < 2 || width < uint64(n) {
+		return []TokenRange{r}
+	}
+	step := width / uint64(n)
+	parts := make([]TokenRange, 0, n)
+	start := r.Start
+	for i := 1; i < n; i++ {
+		end := int64(uint64(r.Start) + step*uint64(i))
+		parts = append(parts, TokenRange{Start: start, End: end})
+		start = end
+	}
+	return append(parts, TokenRange{Start: start, End: r.End})
+}
@@ -0,0 +1,92 @@
+package cassandradb
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertCoversRing checks that ranges are contiguous and cover the ring.
+func assertCoversRing(t *testing.T, ranges []TokenRange) {
+	t.Helper()
+	require.NotEmpty(t, ranges)
+	assert.Equal(t, int64(math.MinInt64), ranges[0].Start)
+	assert.Equal(t, int64(math.MaxInt64), ranges[len(ranges)-1].End)
+	for i, r := range ranges {
+		assert.Less(t, r.Start, r.End, r)
+		if i > 0 {
+			assert.Equal(t, ranges[i-1].End, r.Start)
+		}
+	}
+}
+
+func TestSplitRing(t *testing.T) {
+	ranges := splitRing([]int64{100, -100, 0, 100}, 1)
+	assertCoversRing(t, ranges)
+	assert.Equal(t, []TokenRange{
+		{Start: math.MinInt64, End: -100}, {Start: -100, End: 0}, {Start: 0, End: 100}, {Start: 100, End: math.MaxInt64},
+	}, ranges)
+
+	ranges = splitRing(nil, 16)
+	assertCoversRing(t, ranges)
+	assert.Len(t, ranges, 16)
+
+	ranges = splitRing([]int64{-5, 5}, 10)
+	assertCoversRing(t, ranges)
+	assert.GreaterOrEqual(t, len(ranges), 10)
+}
+
+func TestScanRetriesFailedRanges(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	var mu sync.Mutex
+	var progress []ScanProgress
+	opts := ScanOptions{
+		Parallelism:  2,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+		Ranges:       []TokenRange{{Start: math.MinInt64, End: 0}, {Start: 0, End: math.MaxInt64}},
+		Progress: func(p ScanProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	}
+	err := tbl.ScanWithOptions(opts, func(row interface{}) error { return nil })
+
+	var scanErr *ScanError
+	require.True(t, errors.As(err, &scanErr))
+	assert.ElementsMatch(t, opts.Ranges, scanErr.Ranges)
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+
+	// every range is tried once and retried twice
+	require.Len(t, *stmts, 6)
+	assert.Equal(t, "SELECT tenant, region, day, hour, views, score, page FROM ks.views "+
+		"WHERE token(tenant,region) > ? AND token(tenant,region) <= ?;", (*stmts)[0])
+	require.Len(t, progress, 2)
+	assert.Equal(t, 2, progress[1].RangesFailed)
+	assert.Equal(t, 2, progress[1].Ranges)
+
+	// the caller hears of every retry, with the error of the failed attempt
+	var retries []TokenRange
+	opts.Parallelism = 1
+	opts.Progress = nil
+	opts.RangeRetry = func(r TokenRange, err error) {
+		assert.ErrorIs(t, err, ops.ErrUnavailable)
+		retries = append(retries, r)
+	}
+	tbl.ScanWithOptions(opts, func(row interface{}) error { return nil })
+	assert.Equal(t, []TokenRange{opts.Ranges[0], opts.Ranges[0], opts.Ranges[1], opts.Ranges[1]}, retries)
+}
+
+func TestScanNeedsTokenMetadata(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, pageView{})
+	err := tbl.Scan(4, func(row interface{}) error { return nil })
+	assert.ErrorIs(t, err, ops.ErrUnavailable)
+	assert.Equal(t, []string{localTokensStmt}, *stmts)
+}
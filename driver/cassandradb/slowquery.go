@@ -0,0 +1,256 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// SlowQueryConfig controls the slow query log.
+type SlowQueryConfig struct {
+	// Threshold is the duration above which a statement is recorded as slow,
+	// the slow query log is off when it is zero.
+	Threshold time.Duration `toml:"threshold"`
+	// Trace re-runs slow reads with Cassandra tracing and attaches the
+	// trace events to the record. Only the first page is fetched again.
+	Trace bool `toml:"trace"`
+	// TraceTimeout bounds the re-run and the trace lookup.
+	TraceTimeout time.Duration `toml:"trace_timeout"`
+	// MaxTraces caps the statements traced again at once, defaults to 2.
+	// Slow reads above the cap are recorded without a trace.
+	MaxTraces int `toml:"max_traces"`
+	// TraceInterval is the time before a statement, compared without its
+	// values, is traced again, defaults to a minute.
+	TraceInterval time.Duration `toml:"trace_interval"`
+	// Sink receives the slow queries, they are logged at warn level when unset.
+	Sink ops.SlowQuerySink `toml:"-"`
+}
+
+const (
+	defaultTraceTimeout  = 5 * time.Second
+	defaultMaxTraces     = 2
+	defaultTraceInterval = time.Minute
+	// maxTracedStatements bounds the statements remembered for
+	// TraceInterval, the expired ones are dropped when it is reached
+	maxTracedStatements = 1024
+	// trace events are written by the cluster in the background, the lookup
+	// is retried a few times before it gives up
+	traceLookupAttempts = 5
+	traceLookupInterval = 100 * time.Millisecond
+)
+
+const traceEventsStmt = `SELECT event_id, activity, source, source_elapsed, thread
+	FROM system_traces.events WHERE session_id = ?`
+
+var (
+	errTraceBusy   = errors.New("trace skipped, too many traces in flight")
+	errTraceRecent = errors.New("trace skipped, statement traced recently")
+)
+
+func (cn *conn) setSlowQuery(cfg SlowQueryConfig) {
+	if cfg.TraceTimeout <= 0 {
+		cfg.TraceTimeout = defaultTraceTimeout
+	}
+	if cfg.MaxTraces <= 0 {
+		cfg.MaxTraces = defaultMaxTraces
+	}
+	if cfg.TraceInterval <= 0 {
+		cfg.TraceInterval = defaultTraceInterval
+	}
+	cn.Lock()
+	defer cn.Unlock()
+	cn.slow = cfg
+	cn.traces = newTraceLimiter(cfg.MaxTraces, cfg.TraceInterval)
+}
+
+func (cn *conn) slowQueryConfig() (SlowQueryConfig, *traceLimiter) {
+	cn.RLock()
+	defer cn.RUnlock()
+	return cn.slow, cn.traces
+}
+
+// traceLimiter bounds the statements traced again at once, and traces a
+// statement at most once per interval.
+type traceLimiter struct {
+	sync.Mutex
+	slots    chan struct{}
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+func newTraceLimiter(max int, interval time.Duration) *traceLimiter {
+	return &traceLimiter{slots: make(chan struct{}, max), interval: interval, last: make(map[string]time.Time)}
+}
+
+// acquire takes a slot to trace stmt at now, release must be called when
+// the trace is done.
+func (l *traceLimiter) acquire(stmt string, now time.Time) error {
+	l.Lock()
+	defer l.Unlock()
+	if at, ok := l.last[stmt]; ok && now.Sub(at) < l.interval {
+		return errTraceRecent
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		return errTraceBusy
+	}
+	if len(l.last) >= maxTracedStatements {
+		for s, at := range l.last {
+			if now.Sub(at) >= l.interval {
+				delete(l.last, s)
+			}
+		}
+	}
+	l.last[stmt] = now
+	return nil
+}
+
+func (l *traceLimiter) release() {
+	<-l.slots
+}
+
+// checkSlowQuery records the statement when it ran longer than the slow
+// query threshold. Statements that are traced are recorded once the trace
+// has been captured, in the background.
+func (cn *conn) checkSlowQuery(info ops.QueryInfo, values []interface{}, result ops.QueryResult, err error) {
+	cfg, traces := cn.slowQueryConfig()
+	if cfg.Threshold <= 0 || result.Duration < cfg.Threshold {
+		return
+	}
+	redacted := redactCQL(info.Statement)
+	record := ops.SlowQuery{
+		Operation: info.Operation,
+		Keyspace:  info.Keyspace,
+		Table:     info.Table,
+		Statement: cn.statement(info.Statement),
+		Rows:      result.Rows,
+		Duration:  result.Duration,
+		At:        time.Now(),
+		Err:       err,
+		FullScan:  isFullScan(redacted),
+	}
+	sink := cfg.Sink
+	if sink == nil {
+		sink = ops.NewSlowQueryLogger(cn.log())
+	}
+	if !cfg.Trace {
+		sink.RecordSlowQuery(record)
+		return
+	}
+	if !isRead(redacted) {
+		record.TraceErr = fmt.Errorf("only reads are traced again")
+		sink.RecordSlowQuery(record)
+		return
+	}
+	if record.TraceErr = traces.acquire(redacted, record.At); record.TraceErr != nil {
+		sink.RecordSlowQuery(record)
+		return
+	}
+	go func() {
+		defer traces.release()
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.TraceTimeout)
+		defer cancel()
+		record.Trace, record.TraceErr = cn.traceStatement(ctx, info.Statement, values)
+		sink.RecordSlowQuery(record)
+	}()
+}
+
+// traceCollector implements gocql.Tracer and keeps the id of the trace
+// session started by the statement.
+type traceCollector struct {
+	sync.Mutex
+	id []byte
+}
+
+func (t *traceCollector) Trace(traceID []byte) {
+	t.Lock()
+	defer t.Unlock()
+	t.id = append([]byte{}, traceID...)
+}
+
+func (t *traceCollector) traceID() []byte {
+	t.Lock()
+	defer t.Unlock()
+	return t.id
+}
+
+// traceStatement runs the first page of stmt again with tracing on and
+// returns the trace events recorded by the cluster. It does not go through
+// the hooks, the metrics or the slow query log.
+func (cn *conn) traceStatement(ctx context.Context, stmt string, values []interface{}) ([]ops.TraceEvent, error) {
+	dbSession := cn.session()
+	if dbSession == nil {
+		return nil, gocql.ErrNoConnections
+	}
+	tracer := &traceCollector{}
+	iter := dbSession.Query(stmt, values...).WithContext(ctx).Trace(tracer).Iter()
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	id := tracer.traceID()
+	if id == nil {
+		return nil, fmt.Errorf("no trace returned for statement")
+	}
+
+	for attempt := 0; attempt < traceLookupAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(traceLookupInterval):
+			}
+		}
+		var events []ops.TraceEvent
+		var ev ops.TraceEvent
+		var eventID gocql.UUID
+		var elapsed int
+		iter := dbSession.Query(traceEventsStmt, id).WithContext(ctx).Consistency(gocql.One).Iter()
+		for iter.Scan(&eventID, &ev.Activity, &ev.Source, &elapsed, &ev.Thread) {
+			ev.At = eventID.Time()
+			ev.Elapsed = time.Duration(elapsed) * time.Microsecond
+			events = append(events, ev)
+			ev = ops.TraceEvent{}
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			return events, nil
+		}
+	}
+	return nil, fmt.Errorf("no trace events recorded for statement")
+}
+
+func isRead(stmt string) bool {
+	fields := strings.Fields(stmt)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "SELECT")
+}
+
+// isFullScan reports whether stmt is a read that is not restricted by a
+// WHERE clause or needs ALLOW FILTERING. stmt must have its literals
+// redacted so that values are not mistaken for keywords.
+func isFullScan(stmt string) bool {
+	if !isRead(stmt) {
+		return false
+	}
+	fields := strings.Fields(strings.ToUpper(stmt))
+	where := false
+	for i, f := range fields {
+		switch f {
+		case "WHERE":
+			where = true
+		case "ALLOW":
+			if i+1 < len(fields) && strings.TrimSuffix(fields[i+1], ";") == "FILTERING" {
+				return true
+			}
+		}
+	}
+	return !where
+}
@@ -0,0 +1,88 @@
+package cassandradb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQueryRecorded(t *testing.T) {
+	ring := ops.NewSlowQueryRing(4)
+	cn := newConn(nil)
+	cn.setSlowQuery(SlowQueryConfig{Threshold: time.Nanosecond, Sink: ring})
+
+	info := ops.QueryInfo{Operation: ops.OpList, Keyspace: "ks", Table: "users",
+		Statement: "SELECT id, name FROM ks.users WHERE name = 'bob' ALLOW FILTERING"}
+	err := cn.exec(context.Background(), info)
+	require.Error(t, err)
+
+	records := ring.Records()
+	require.Len(t, records, 1)
+	rec := records[0]
+	assert.Equal(t, ops.OpList, rec.Operation)
+	assert.Equal(t, "users", rec.Table)
+	assert.Equal(t, "SELECT id, name FROM ks.users WHERE name = ? ALLOW FILTERING", rec.Statement)
+	assert.True(t, rec.FullScan)
+	assert.ErrorIs(t, rec.Err, ops.ErrUnavailable)
+	assert.Nil(t, rec.Trace)
+}
+
+func TestSlowQueryBelowThreshold(t *testing.T) {
+	ring := ops.NewSlowQueryRing(4)
+	cn := newConn(nil)
+	cn.setSlowQuery(SlowQueryConfig{Threshold: time.Hour, Sink: ring})
+
+	cn.exec(context.Background(), ops.QueryInfo{Operation: ops.OpRead, Statement: "SELECT * FROM ks.users"})
+	assert.Empty(t, ring.Records())
+}
+
+func TestIsFullScan(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT id FROM ks.users":                                 true,
+		"SELECT id FROM ks.users WHERE id = ?":                    false,
+		"select id from ks.users where name = ? allow filtering;": true,
+		"SELECT id FROM ks.users  WHERE id = ? LIMIT 10":          false,
+		"DELETE FROM ks.users WHERE id = ?":                       false,
+		"INSERT INTO ks.users (id) VALUES (?)":                    false,
+	}
+	for stmt, want := range cases {
+		assert.Equal(t, want, isFullScan(stmt), stmt)
+	}
+}
+
+func TestSlowQueryTraceLimits(t *testing.T) {
+	l := newTraceLimiter(2, time.Minute)
+	now := time.Now()
+	require.NoError(t, l.acquire("SELECT a", now))
+	// a statement is traced once per interval
+	assert.ErrorIs(t, l.acquire("SELECT a", now.Add(time.Second)), errTraceRecent)
+	require.NoError(t, l.acquire("SELECT b", now))
+	// no more than two traces at once
+	assert.ErrorIs(t, l.acquire("SELECT c", now), errTraceBusy)
+
+	l.release()
+	require.NoError(t, l.acquire("SELECT c", now))
+	l.release()
+	l.release()
+	require.NoError(t, l.acquire("SELECT a", now.Add(time.Minute)))
+}
+
+func TestSlowQueryTraceDropped(t *testing.T) {
+	ring := ops.NewSlowQueryRing(4)
+	cn := newConn(nil)
+	cn.setSlowQuery(SlowQueryConfig{Threshold: time.Nanosecond, Trace: true, MaxTraces: 1, Sink: ring})
+	_, traces := cn.slowQueryConfig()
+	require.NoError(t, traces.acquire("SELECT busy", time.Now()))
+
+	// the slot is taken, the read is recorded right away without a trace
+	info := ops.QueryInfo{Operation: ops.OpRead, Statement: "SELECT * FROM ks.users WHERE id = 1"}
+	cn.exec(context.Background(), info)
+	records := ring.Records()
+	require.Len(t, records, 1)
+	assert.ErrorIs(t, records[0].TraceErr, errTraceBusy)
+	assert.Nil(t, records[0].Trace)
+}
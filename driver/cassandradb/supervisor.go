@@ -0,0 +1,348 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+)
+
+// ConnState is the connection state of a Client.
+type ConnState string
+
+const (
+	StateDisconnected ConnState = "disconnected"
+	StateConnected    ConnState = "connected"
+	StateReconnecting ConnState = "reconnecting"
+	StateClosed       ConnState = "closed"
+)
+
+// SupervisorConfig controls the background connection supervisor.
+// When enabled the client pings the cluster every PingInterval and, when
+// FailureThreshold pings in a row fail, reconnects with an exponential
+// backoff between BackoffMin and BackoffMax with random jitter.
+type SupervisorConfig struct {
+	Enabled          bool          `toml:"enabled"`
+	PingInterval     time.Duration `toml:"ping_interval"`
+	PingTimeout      time.Duration `toml:"ping_timeout"`
+	FailureThreshold int           `toml:"failure_threshold"`
+	BackoffMin       time.Duration `toml:"backoff_min"`
+	BackoffMax       time.Duration `toml:"backoff_max"`
+}
+
+// StateChange is delivered to state change callbacks and channels.
+type StateChange struct {
+	From ConnState
+	To   ConnState
+	Err  error
+	At   time.Time
+}
+
+// Health is a point in time report of the client connection, suitable
+// for readiness probes.
+type Health struct {
+	State          ConnState
+	LastError      error
+	LastErrorAt    time.Time
+	LastPing       time.Time
+	ReconnectCount int64
+	Hosts          []string
+}
+
+// Healthy reports whether the client is connected.
+func (h Health) Healthy() bool {
+	return h.State == StateConnected
+}
+
+// healthState tracks the connection state of a Client and the hosts gocql
+// has successfully connected to.
+type healthState struct {
+	sync.Mutex
+	state       ConnState
+	lastErr     error
+	lastErrAt   time.Time
+	lastPing    time.Time
+	hosts       map[string]bool
+	callbacks   []func(StateChange)
+	subscribers []chan StateChange
+}
+
+func (h *healthState) current() ConnState {
+	h.Lock()
+	defer h.Unlock()
+	return h.state
+}
+
+// hostsUp returns the number of hosts with a working connection.
+func (h *healthState) hostsUp() int {
+	n := 0
+	for _, up := range h.hosts {
+		if up {
+			n++
+		}
+	}
+	return n
+}
+
+// connectObserver records the hosts gocql connects to in the client health
+// and the pool metrics.
+type connectObserver struct {
+	c *Client
+}
+
+// ObserveConnect implements gocql.ConnectObserver.
+func (o connectObserver) ObserveConnect(oc gocql.ObservedConnect) {
+	if oc.Host == nil {
+		return
+	}
+	h := &o.c.health
+	h.Lock()
+	if h.hosts == nil {
+		h.hosts = make(map[string]bool)
+	}
+	h.hosts[oc.Host.ConnectAddressAndPort()] = oc.Err == nil
+	up := h.hostsUp()
+	h.Unlock()
+	o.c.conn.metricSink().Set(ops.MetricPoolHosts, nil, float64(up))
+}
+
+// setState records a state change and notifies the registered callbacks and
+// channels. An error is recorded even if the state does not change. A
+// closed client stays closed, only Connect opens it again.
+func (c *Client) setState(to ConnState, err error) {
+	c.changeState(to, err, false)
+}
+
+// changeState is setState, reopen allows leaving the closed state.
+func (c *Client) changeState(to ConnState, err error, reopen bool) {
+	h := &c.health
+	h.Lock()
+	now := time.Now()
+	if err != nil {
+		h.lastErr = err
+		h.lastErrAt = now
+	}
+	from := h.state
+	if from == to || (from == StateClosed && !reopen) {
+		h.Unlock()
+		return
+	}
+	h.state = to
+	if to == StateClosed {
+		h.hosts = nil
+	}
+	up := h.hostsUp()
+	change := StateChange{From: from, To: to, Err: err, At: now}
+	callbacks := append([]func(StateChange){}, h.callbacks...)
+	subscribers := append([]chan StateChange{}, h.subscribers...)
+	h.Unlock()
+
+	m := c.conn.metricSink()
+	for _, state := range []ConnState{StateDisconnected, StateConnected, StateReconnecting, StateClosed} {
+		val := 0.0
+		if state == to {
+			val = 1
+		}
+		m.Set(ops.MetricConnState, ops.Labels{ops.LabelState: string(state)}, val)
+	}
+	m.Set(ops.MetricPoolHosts, nil, float64(up))
+
+	for _, fn := range callbacks {
+		fn(change)
+	}
+	for _, ch := range subscribers {
+		select {
+		case ch <- change:
+		default: // slow subscriber, drop the change
+		}
+	}
+}
+
+// OnStateChange registers a callback that is invoked on every connection
+// state change. Callbacks run on the goroutine that changed the state.
+func (c *Client) OnStateChange(fn func(StateChange)) {
+	c.health.Lock()
+	defer c.health.Unlock()
+	c.health.callbacks = append(c.health.callbacks, fn)
+}
+
+// StateChanges returns a channel receiving connection state changes.
+// Changes are dropped when the channel buffer is full.
+func (c *Client) StateChanges() <-chan StateChange {
+	ch := make(chan StateChange, 16)
+	c.health.Lock()
+	defer c.health.Unlock()
+	c.health.subscribers = append(c.health.subscribers, ch)
+	return ch
+}
+
+// Health returns the current connection health of the client.
+func (c *Client) Health() Health {
+	h := &c.health
+	h.Lock()
+	defer h.Unlock()
+	report := Health{
+		State:          h.state,
+		LastError:      h.lastErr,
+		LastErrorAt:    h.lastErrAt,
+		LastPing:       h.lastPing,
+		ReconnectCount: c.ReconnectCount(),
+	}
+	for host, up := range h.hosts {
+		if up {
+			report.Hosts = append(report.Hosts, host)
+		}
+	}
+	sort.Strings(report.Hosts)
+	return report
+}
+
+// Ping runs a trivial query against the cluster.
+func (c *Client) Ping() error {
+	dbSession := c.conn.session()
+	if dbSession == nil {
+		return gocql.ErrNoConnections
+	}
+	timeout := c.config.Supervisor.PingTimeout
+	if timeout == 0 {
+		timeout = c.config.Timeout
+	}
+	if timeout <= 0 {
+		// a ping never waits forever, even without a query timeout
+		timeout = DefaultConfig.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var version string
+	err := dbSession.Query("SELECT release_version FROM system.local").
+		WithContext(ctx).Consistency(gocql.One).Scan(&version)
+	if err == nil {
+		c.health.Lock()
+		c.health.lastPing = time.Now()
+		c.health.Unlock()
+	}
+	return err
+}
+
+// StartSupervisor starts the background connection supervisor. It is
+// started by NewClientWithConfig when config.Supervisor.Enabled is set.
+func (c *Client) StartSupervisor() {
+	c.Lock()
+	defer c.Unlock()
+	if c.supervisor != nil {
+		return
+	}
+	c.supervisor = make(chan struct{})
+	go c.supervise(c.supervisor)
+}
+
+// StopSupervisor stops the background connection supervisor.
+func (c *Client) StopSupervisor() {
+	c.Lock()
+	defer c.Unlock()
+	if c.supervisor != nil {
+		close(c.supervisor)
+		c.supervisor = nil
+	}
+}
+
+func (c *Client) supervise(stop <-chan struct{}) {
+	cfg := c.config.Supervisor
+	interval := cfg.PingInterval
+	if interval <= 0 {
+		interval = DefaultConfig.Supervisor.PingInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultConfig.Supervisor.FailureThreshold
+	}
+	failures := 0
+	for {
+		if err := c.Ping(); err != nil {
+			failures++
+			if failures < threshold {
+				// a single failed ping does not tear down a working session
+				c.setState(c.health.current(), err)
+			} else {
+				failures = 0
+				c.setState(StateDisconnected, err)
+				if !c.reconnect(stop) {
+					return
+				}
+			}
+		} else {
+			failures = 0
+			c.setState(StateConnected, nil)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconnect reopens the session until a ping succeeds, the client stays
+// reconnecting until then. It returns false if the supervisor was stopped
+// or the client closed first.
+func (c *Client) reconnect(stop <-chan struct{}) bool {
+	cfg := c.config.Supervisor
+	c.setState(StateReconnecting, nil)
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		err := c.reopenSession()
+		if errors.Is(err, errClientClosed) {
+			return false
+		}
+		if errors.Is(err, errNotConfigured) {
+			// there is nothing to reopen before Connect
+			c.setState(StateDisconnected, err)
+			return false
+		}
+		if err == nil {
+			if err = c.Ping(); err == nil {
+				c.setState(StateConnected, nil)
+				return true
+			}
+		}
+		// records the error, the state does not change
+		c.setState(StateReconnecting, err)
+		select {
+		case <-stop:
+			return false
+		case <-time.After(backoff(attempt, cfg.BackoffMin, cfg.BackoffMax)):
+		}
+	}
+}
+
+// backoff returns the delay before retry number attempt, doubling from min
+// up to max, with jitter in the upper half of the interval.
+func backoff(attempt int, min, max time.Duration) time.Duration {
+	if min <= 0 {
+		min = DefaultConfig.Supervisor.BackoffMin
+	}
+	if max < min {
+		max = min
+	}
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
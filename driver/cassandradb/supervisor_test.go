@@ -0,0 +1,178 @@
+package cassandradb
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateGauge records the connection state gauges set by a client.
+type stateGauge struct {
+	sync.Mutex
+	ops.Metrics
+	states map[string]float64
+}
+
+func (g *stateGauge) Set(name string, labels ops.Labels, value float64) {
+	if name != ops.MetricConnState {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	g.states[labels[ops.LabelState]] = value
+}
+
+func newTestClient() (*Client, *stateGauge) {
+	g := &stateGauge{Metrics: ops.NopMetrics, states: make(map[string]float64)}
+	c := &Client{conn: newConn(nil)}
+	c.conn.setMetrics(g)
+	return c, g
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempt  int
+		min, max time.Duration
+		want     time.Duration
+	}{
+		{0, 100 * time.Millisecond, time.Second, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, time.Second, 200 * time.Millisecond},
+		{3, 100 * time.Millisecond, time.Second, 800 * time.Millisecond},
+		{4, 100 * time.Millisecond, time.Second, time.Second},
+		{50, 100 * time.Millisecond, time.Second, time.Second},
+		// max below min is raised to min
+		{5, time.Second, time.Millisecond, time.Second},
+		// a zero min takes the default
+		{0, 0, time.Second, DefaultConfig.Supervisor.BackoffMin},
+	} {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			d := backoff(tc.attempt, tc.min, tc.max)
+			assert.GreaterOrEqual(t, d, tc.want/2, "attempt %d", tc.attempt)
+			assert.LessOrEqual(t, d, tc.want, "attempt %d", tc.attempt)
+			seen[d] = true
+		}
+		// the delays are jittered
+		assert.Greater(t, len(seen), 1, "attempt %d", tc.attempt)
+	}
+}
+
+func TestStateTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		from, to ConnState
+		reopen   bool
+		want     ConnState
+	}{
+		{StateDisconnected, StateConnected, false, StateConnected},
+		{StateConnected, StateDisconnected, false, StateDisconnected},
+		{StateDisconnected, StateReconnecting, false, StateReconnecting},
+		{StateReconnecting, StateConnected, false, StateConnected},
+		{StateConnected, StateClosed, false, StateClosed},
+		// a closed client only comes back through Connect
+		{StateClosed, StateConnected, false, StateClosed},
+		{StateClosed, StateReconnecting, false, StateClosed},
+		{StateClosed, StateDisconnected, false, StateClosed},
+		{StateClosed, StateConnected, true, StateConnected},
+		{StateClosed, StateDisconnected, true, StateDisconnected},
+	} {
+		c, _ := newTestClient()
+		c.health.state = tc.from
+		var changes []StateChange
+		c.OnStateChange(func(sc StateChange) { changes = append(changes, sc) })
+
+		c.changeState(tc.to, nil, tc.reopen)
+		assert.Equal(t, tc.want, c.health.current(), "%s -> %s", tc.from, tc.to)
+		if tc.want == tc.from {
+			assert.Empty(t, changes, "%s -> %s", tc.from, tc.to)
+		} else {
+			require.Len(t, changes, 1)
+			assert.Equal(t, tc.from, changes[0].From)
+			assert.Equal(t, tc.to, changes[0].To)
+		}
+	}
+}
+
+func TestStateChangeDelivery(t *testing.T) {
+	c, gauge := newTestClient()
+	var changes []StateChange
+	c.OnStateChange(func(sc StateChange) { changes = append(changes, sc) })
+	ch := c.StateChanges()
+
+	errDown := errors.New("down")
+	c.setState(StateConnected, nil)
+	c.setState(StateDisconnected, errDown)
+	// an unchanged state records the error without a notification
+	errAgain := errors.New("still down")
+	c.setState(StateDisconnected, errAgain)
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, StateConnected, changes[0].To)
+	assert.Equal(t, errDown, changes[1].Err)
+	assert.Equal(t, StateConnected, (<-ch).To)
+	assert.Equal(t, StateDisconnected, (<-ch).To)
+	assert.Len(t, ch, 0)
+
+	assert.Equal(t, 1.0, gauge.states[string(StateDisconnected)])
+	assert.Equal(t, 0.0, gauge.states[string(StateConnected)])
+
+	health := c.Health()
+	assert.False(t, health.Healthy())
+	assert.Equal(t, errAgain, health.LastError)
+
+	// a full channel drops changes rather than blocking
+	for i := 0; i < 20; i++ {
+		c.setState(StateConnected, nil)
+		c.setState(StateDisconnected, nil)
+	}
+	assert.Len(t, ch, cap(ch))
+}
+
+func TestHealthHosts(t *testing.T) {
+	c, _ := newTestClient()
+	c.setState(StateConnected, nil)
+	obs := connectObserver{c}
+	obs.ObserveConnect(gocql.ObservedConnect{Host: hostInfo("10.0.0.2")})
+	obs.ObserveConnect(gocql.ObservedConnect{Host: hostInfo("10.0.0.1")})
+	obs.ObserveConnect(gocql.ObservedConnect{Host: hostInfo("10.0.0.3"), Err: errors.New("refused")})
+
+	health := c.Health()
+	assert.True(t, health.Healthy())
+	assert.Equal(t, []string{"10.0.0.1:0", "10.0.0.2:0"}, health.Hosts)
+
+	c.setState(StateClosed, nil)
+	assert.Empty(t, c.Health().Hosts)
+}
+
+func hostInfo(ip string) *gocql.HostInfo {
+	return (&gocql.HostInfo{}).SetConnectAddress(net.ParseIP(ip))
+}
+
+func TestReConnectAfterDisconnect(t *testing.T) {
+	c, _ := newTestClient()
+	// not configured, not counted as a reconnect
+	assert.Error(t, c.ReConnect())
+	assert.Zero(t, c.ReconnectCount())
+	// a supervisor started before Connect gives up rather than retrying
+	assert.False(t, c.reconnect(make(chan struct{})))
+	assert.Equal(t, StateDisconnected, c.Health().State)
+	assert.ErrorIs(t, c.Health().LastError, errNotConfigured)
+
+	c.clusterCfg = gocql.NewCluster("127.0.0.1")
+	c.setState(StateConnected, nil)
+	require.NoError(t, c.Disconnect())
+	assert.ErrorIs(t, c.ReConnect(), errClientClosed)
+	assert.Zero(t, c.ReconnectCount())
+	assert.Nil(t, c.conn.session())
+	assert.Equal(t, StateClosed, c.Health().State)
+
+	// the supervisor gives up on a closed client
+	assert.False(t, c.reconnect(make(chan struct{})))
+	assert.Equal(t, StateClosed, c.Health().State)
+}
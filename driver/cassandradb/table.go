@@ -2,14 +2,16 @@ package cassandradb
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
 	"sync"
 	"time"
 
 	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
 	"github.com/meooio/goava/whc"
 )
 
@@ -18,13 +20,59 @@ import (
 
 type Table struct {
 	sync.RWMutex
-	dbSession *gocql.Session
+	conn      *conn
+	ctx       context.Context
 	Name      string
 	KeySpace  string
 	entities  []Entity
 	dataModel interface{}
 	createdAt time.Time
 	updatedAt time.Time
+	// columns is the projection set by Select, nil selects every column
+	columns []string
+	// stmts caches the generated statements, shared by the copies
+	stmts *stmtCache
+}
+
+// WithContext returns a copy of the table whose statements run with ctx.
+// The context is passed to the query hooks and cancels the statements.
+func (t *Table) WithContext(ctx context.Context) *Table {
+	return &Table{
+		conn:      t.conn,
+		ctx:       ctx,
+		Name:      t.Name,
+		KeySpace:  t.KeySpace,
+		entities:  t.entities,
+		dataModel: t.dataModel,
+		createdAt: t.createdAt,
+		updatedAt: t.updatedAt,
+		columns:   t.columns,
+		stmts:     t.stmts,
+	}
+}
+
+func (t *Table) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// TableName returns the name of the table.
+func (t *Table) TableName() string {
+	return t.Name
+}
+
+// DataModel returns the struct value the table was created from, or last
+// altered to.
+func (t *Table) DataModel() interface{} {
+	t.RLock()
+	defer t.RUnlock()
+	return t.dataModel
+}
+
+func (t *Table) queryInfo(op string, stmt string) ops.QueryInfo {
+	return ops.QueryInfo{Operation: op, Keyspace: t.KeySpace, Table: t.Name, Statement: stmt}
 }
 
 /*
@@ -51,205 +99,60 @@ type WhereClauseType struct {
 }
 */
 
-// AlterTable
+// AlterTable drops the cached statements of the table, they are built again
+// for the schema the table has after the change.
 func (t *Table) AlterTable(data interface{}) error {
-
+	t.stmts.invalidate()
 	return nil
 }
 
 // InsertRow
 func (t *Table) Insert(data interface{}) error {
-
-	var buffer bytes.Buffer
-	buffer.WriteString("INSERT INTO ")
-	buffer.WriteString(t.KeySpace)
-	buffer.WriteString(".")
-	buffer.WriteString(t.Name)
-	buffer.WriteString(" (")
-
-	counter := len(t.entities)
-	for _, entity := range t.entities {
-		buffer.WriteString(entity.columnName)
-		if counter > 1 {
-			buffer.WriteString(", ")
-			counter--
-		} else {
-			buffer.WriteString(") ")
-		}
+	row, err := t.beforeInsert(data)
+	if err != nil {
+		return err
 	}
-	buffer.WriteString("VALUES (")
-
-	var v = reflect.ValueOf(data)
-
-	if v.Kind() == reflect.Struct {
-		// fmt.Println("\n is a struct")
+	stmt, values, err := t.insertStatement(row, false)
+	if err != nil {
+		return err
 	}
-
-	val := reflect.ValueOf(data).Elem()
-
-	for i := 0; i < val.NumField(); i++ {
-		valueField := val.Field(i)
-		kindType := valueField.Kind()
-		typeField := val.Type().Field(i)
-		log.Printf("Type : %s , Value : %v , Kind : %s", typeField.Name, valueField.Interface(), kindType)
-
-		if kindType == reflect.Map {
-			var mapBuffer bytes.Buffer
-			mapBuffer.WriteString("{")
-			iface := valueField.Interface()
-			a := iface.(map[string]string)
-
-			flag := true
-			// for _, key := range valueField.MapKeys() {
-			for k, v := range a {
-				if flag {
-					flag = false
-				} else {
-					mapBuffer.WriteString(", ")
-				}
-				mapBuffer.WriteString(checkTypeAndWrite(t.entities[i].columnKeyType, k))
-				mapBuffer.WriteString(" : ")
-				mapBuffer.WriteString(checkTypeAndWrite(t.entities[i].columnValType, v))
-			}
-			mapBuffer.WriteString("}")
-			buffer.WriteString(mapBuffer.String())
-
-		} else if kindType == reflect.Slice {
-			if t.entities[i].columnSubType == "set" {
-				s := reflect.ValueOf(valueField.Interface())
-				var setBuffer bytes.Buffer
-				setBuffer.WriteString("{")
-				flag := true
-				for j := 0; j < s.Len(); j++ {
-					if flag {
-						flag = false
-					} else {
-						setBuffer.WriteString(", ")
-					}
-					setBuffer.WriteString(checkTypeAndWrite(t.entities[i].columnValType, s.Index(j)))
-				}
-				setBuffer.WriteString("}")
-				buffer.WriteString(setBuffer.String())
-
-			} else if t.entities[i].columnSubType == "list" {
-				s := reflect.ValueOf(valueField.Interface())
-				var listBuffer bytes.Buffer
-				listBuffer.WriteString("[")
-				flag := true
-				for j := 0; j < s.Len(); j++ {
-					if flag {
-						flag = false
-					} else {
-						listBuffer.WriteString(", ")
-					}
-					listBuffer.WriteString(checkTypeAndWrite(t.entities[i].columnValType, s.Index(j)))
-				}
-				listBuffer.WriteString("]")
-				buffer.WriteString(listBuffer.String())
-			}
-
-		} else {
-			// buffer.WriteString(fmt.Sprintf("%v",valueField.Interface()))
-			buffer.WriteString(checkTypeAndWrite(t.entities[i].columnType, valueField.Interface()))
-		}
-
-		if i < val.NumField()-1 {
-			buffer.WriteString(", ")
-		} else {
-			buffer.WriteString(");")
-		}
+	if err := t.conn.exec(t.context(), t.queryInfo(ops.OpInsert, stmt), values...); err != nil {
+		return err
 	}
-	log.Printf("insert query : %s", buffer.String())
+	return t.afterInsert(row)
+}
 
-	if err := t.dbSession.Query(buffer.String()).Exec(); err != nil {
+// InsertIfNotExists inserts the row as a lightweight transaction. It fails
+// with ops.ErrConflict when a row with the same primary key already exists.
+func (t *Table) InsertIfNotExists(data interface{}) error {
+	row, err := t.beforeInsert(data)
+	if err != nil {
 		return err
 	}
-	return nil
-
+	stmt, values, err := t.insertStatement(row, true)
+	if err != nil {
+		return err
+	}
+	if err := t.conn.execCAS(t.context(), t.queryInfo(ops.OpInsert, stmt), values...); err != nil {
+		return err
+	}
+	return t.afterInsert(row)
 }
 
 // DeleteRows deletes one or more rows from a Cassandra table
 func (t *Table) Delete(deleteColumnList []string, whereClause []whc.WhereClauseType) error {
-
-	var buffer bytes.Buffer
-	buffer.WriteString("DELETE ")
-	if len(deleteColumnList) > 0 {
-		// fmt.Printf("delete columns: %v", deleteColumnList)
-		flag := true
-		for _, v := range deleteColumnList {
-			if flag {
-				flag = false
-			} else {
-				buffer.WriteString(" , ")
-			}
-			buffer.WriteString(v)
-		}
-	}
-
-	buffer.WriteString(" FROM ")
-	buffer.WriteString(t.KeySpace)
-	buffer.WriteString(".")
-	buffer.WriteString(t.Name)
-
-	if len(whereClause) == 0 {
-		return errors.New(fmt.Sprintf("cannot delete without where clause: %s", t.Name))
+	stmt, values, err := t.deleteStatement(deleteColumnList, whereClause)
+	if err != nil {
+		return err
 	}
-	buffer.WriteString(" WHERE ")
-	flag := true
-	for i := 0; i < len(whereClause); i++ {
-		if flag {
-			flag = false
-		} else {
-			buffer.WriteString(" AND ")
-		}
-		wc := whereClause[i]
-		fieldFound := false
-		for _, entity := range t.entities {
-			if entity.columnName == wc.ColumnName {
-				wc := whereClause[i]
-				buffer.WriteString(wc.ColumnName)
-				buffer.WriteString(" ")
-				buffer.WriteString(wc.RelationType)
-				buffer.WriteString(" ")
-				if wc.RelationType == "in" {
-					buffer.WriteString("(")
-					v := wc.ColumnValue
-					switch reflect.TypeOf(v).Kind() {
-					case reflect.Slice:
-						s := reflect.ValueOf(v)
-						if s.Len() == 0 {
-							return errors.New(fmt.Sprintf("invalid where clause in delete query, no values for \"in\" operator : %s", wc.ColumnName))
-						}
-						commaFlag := true
-						for j := 0; j < s.Len(); j++ {
-							if commaFlag {
-								commaFlag = false
-							} else {
-								buffer.WriteString(", ")
-							}
-							buffer.WriteString(fmt.Sprintf("%v", s.Index(j)))
-						}
-					default:
-						return errors.New(fmt.Sprintf("invalid datatype in update whereClause , should be an array when using \"in\" : %s", wc.ColumnName))
-					}
-					buffer.WriteString(")")
-				} else {
-					buffer.WriteString(checkTypeAndWrite(entity.columnType, wc.ColumnValue))
-				}
-				fieldFound = true
-				break
-			}
-		}
-		if !fieldFound {
-			return errors.New(fmt.Sprintf("invalid field in update where clause :: %s", wc.ColumnName))
-		}
+	model := t.deleteModel()
+	if err := t.beforeDelete(model, whereClause); err != nil {
+		return err
 	}
-	buffer.WriteString(";")
-	// fmt.Printf("delete query : %s \n", buffer.String())
-	if err := t.dbSession.Query(buffer.String()).Exec(); err != nil {
+	if err := t.conn.exec(t.context(), t.queryInfo(ops.OpDelete, stmt), values...); err != nil {
 		return err
 	}
-	return nil
+	return t.afterDelete(model, whereClause)
 }
 
 // Updates a Row where the entire updated row is supplied. This is different from
@@ -265,9 +168,19 @@ func (t *Table) Update(x interface{}) error {
 	// v := reflect.ValueOf(x)
 	// fmt.Printf("\n\n********\n inside update full object :: %v\n", x)
 
-	s := reflect.ValueOf(x)
-	if s.Kind() == reflect.Ptr {
-		s = s.Elem()
+	row, err := t.beforeUpdate(x)
+	if err != nil {
+		return err
+	}
+	s := reflect.ValueOf(row).Elem()
+	now := autoNow()
+	for _, entity := range t.entities {
+		if entity.auto == autoUpdated {
+			autoValue(entity, s.FieldByName(entity.fieldName), now, false)
+		}
+	}
+	if err := t.validateRow(ops.OpUpdate, s); err != nil {
+		return err
 	}
 	// typeOfS := s.Type()
 	var whereClause = []whc.WhereClauseType{}
@@ -284,7 +197,8 @@ func (t *Table) Update(x interface{}) error {
 			// fmt.Printf("field name :: %s\n", entity.fieldName)
 			// fmt.Printf("field name from struct :: %s\n", fieldName)
 
-			if fieldName == entity.fieldName {
+			// the created time is written by the insert only
+			if fieldName == entity.fieldName && entity.auto != autoCreated {
 				if entity.primaryKey {
 					w := whc.WhereClauseType{
 						ColumnName:   entity.columnName,
@@ -310,7 +224,10 @@ func (t *Table) Update(x interface{}) error {
 	}
 	// fmt.Printf("Update map :: %v\n", updates)
 	// fmt.Printf("Update where clause :: %v\n", whereClause)
-	return t.UpdateFields(updates, nil, whereClause)
+	if err := t.updateFields(updates, nil, whereClause); err != nil {
+		return err
+	}
+	return t.afterUpdate(row)
 }
 
 // UpdateFields updates one or more fields in a given Cassandra table row
@@ -318,181 +235,28 @@ func (t *Table) Update(x interface{}) error {
 func (t *Table) UpdateFields(updateMap, updateParm map[string]interface{},
 	whereClause []whc.WhereClauseType) error {
 
-	// https://gist.github.com/drewolson/4771479
-	// https://play.golang.org/p/Cj9oPPGSLM
-
-	// var updateFields map[string]interface{}
-	// updateFields := make(map[string]interface{})
-
-	// fmt.Printf("Update field and values : %v\n", updateMap)
-	if updateMap == nil {
-		return errors.New("Nothing to update")
+	updateMap, err := t.autoUpdate(updateMap)
+	if err != nil {
+		return err
 	}
-
-	var buffer bytes.Buffer
-	buffer.WriteString("UPDATE ")
-	buffer.WriteString(t.KeySpace)
-	buffer.WriteString(".")
-	buffer.WriteString(t.Name)
-	buffer.WriteString(" ")
-
-	if len(updateParm) > 0 {
-		buffer.WriteString("USING ")
-		flag := true
-		for k, v := range updateParm {
-			if flag {
-				flag = false
-			} else {
-				buffer.WriteString(" AND ")
-			}
-			buffer.WriteString(k)
-			buffer.WriteString(" ")
-			buffer.WriteString(fmt.Sprintf("%v", v))
-		}
+	if err := t.validateUpdate(updateMap, whereClause); err != nil {
+		return err
 	}
+	return t.updateFields(updateMap, updateParm, whereClause)
+}
 
-	if len(updateMap) > 0 {
-		buffer.WriteString(" SET ")
-		flag := true
-		for k, v := range updateMap {
-			// fmt.Printf("Key :: %v\n", k)
-			// fmt.Printf("Val :: %v\n", v)
-			if flag {
-				flag = false
-			} else {
-				buffer.WriteString(" , ")
-			}
-			for _, entity := range t.entities {
-				// fmt.Printf("column name :: %s\n", entity.columnName)
-				// fmt.Printf("field name :: %s\n", entity.fieldName)
-				if k == entity.columnName {
-					// fmt.Println("Entity columntype " + entity.columnType)
-					if entity.columnType == "counter" {
-						switch reflect.TypeOf(v).Kind() {
-						case reflect.Slice:
-							s := reflect.ValueOf(v)
-							if s.Len() != 2 {
-								return errors.New(fmt.Sprintf("invalid update values for counter field : %s", k))
-							}
-							buffer.WriteString(k)
-							buffer.WriteString(" = ")
-							buffer.WriteString(k)
-							buffer.WriteString(" ")
-							buffer.WriteString(fmt.Sprintf("%v", s.Index(0)))
-							buffer.WriteString(" ")
-							buffer.WriteString(fmt.Sprintf("%v", s.Index(1)))
-						}
-					} else if entity.columnType == "collection" {
-						openBracket := "{"
-						closeBracket := "}"
-						if entity.columnSubType == "list" {
-							openBracket = "["
-							closeBracket = "]"
-						}
-						switch reflect.TypeOf(v).Kind() {
-						case reflect.Slice:
-							s := reflect.ValueOf(v)
-							// fmt.Printf("S KIND 1:: %v\n", s.Kind())
-							/*if s.Kind() == reflect.Ptr {
-							    s = s.Elem()
-							    fmt.Printf("S KIND 2:: %v\n", s.Kind())
-							    fmt.Printf("S value :: %v\n", s.Interface())
-							}*/
-
-							if s.Len() < 2 {
-								return errors.New(fmt.Sprintf("too few values for set field : %s", k))
-							}
-							buffer.WriteString(k)
-							buffer.WriteString(" = ")
-							setterType := fmt.Sprintf("%s", s.Index(0))
-							switch setterType {
-							case "all":
-								buffer.WriteString(openBracket)
-								// fmt.Printf("COLL VALUES :: %v\n", s.Index(1))
-								colStr, errBuf := getCollectionToBuffer(s.Index(1).Interface(), entity.columnName, entity.columnSubType)
-								if errBuf != nil {
-									return errBuf
-								}
-								// fmt.Printf("collection buffer :: %s\n", colStr)
-								buffer.WriteString(colStr)
-								buffer.WriteString(closeBracket)
-							case "add":
-								buffer.WriteString(k)
-								buffer.WriteString(" ")
-								buffer.WriteString("+ ")
-								buffer.WriteString(openBracket)
-								colStr, errBuf := getCollectionToBuffer(s.Index(1).Interface(), entity.columnName, entity.columnSubType)
-								if errBuf != nil {
-									return errBuf
-								}
-								// fmt.Printf("collection buffer :: %s\n", colStr)
-								buffer.WriteString(colStr)
-								buffer.WriteString(closeBracket)
-							case "remove":
-								buffer.WriteString(k)
-								buffer.WriteString(" ")
-								buffer.WriteString("- ")
-								buffer.WriteString(openBracket)
-								colStr, errBuf := getCollectionToBuffer(s.Index(1).Interface(), entity.columnName, entity.columnSubType)
-								if errBuf != nil {
-									return errBuf
-								}
-								// fmt.Printf("collection buffer :: %s\n", colStr)
-								buffer.WriteString(colStr)
-								buffer.WriteString(closeBracket)
-							}
-						default:
-							return errors.New(fmt.Sprintf("invalid update values for set or list field : %s", k))
-						}
-					} else { // regular column types
-						buffer.WriteString(k)
-						buffer.WriteString(" = ")
-						// buffer.WriteString(fmt.Sprintf("%v", v))
-						buffer.WriteString(checkTypeAndWrite(entity.columnType, v))
-					}
-					break
-				}
-			}
-
-		}
-	}
+// updateFields is UpdateFields without the validation of the values.
+func (t *Table) updateFields(updateMap, updateParm map[string]interface{},
+	whereClause []whc.WhereClauseType) error {
 
-	buffer.WriteString(" WHERE")
-	buffer.WriteString(" ")
-	if len(whereClause) == 0 {
-		return errors.New("no where clause in update statement")
+	if len(updateMap) == 0 {
+		return t.invalidQuery(ops.OpUpdate, "nothing to update")
 	}
-	flag := true
-	for i := 0; i < len(whereClause); i++ {
-		if flag {
-			flag = false
-		} else {
-			buffer.WriteString(" AND ")
-		}
-		wc := whereClause[i]
-		fieldFound := false
-		for _, entity := range t.entities {
-			if entity.columnName == wc.ColumnName {
-				wc := whereClause[i]
-				buffer.WriteString(wc.ColumnName)
-				buffer.WriteString(" ")
-				buffer.WriteString(wc.RelationType)
-				buffer.WriteString(" ")
-				buffer.WriteString(checkTypeAndWrite(entity.columnType, wc.ColumnValue))
-				fieldFound = true
-				break
-			}
-		}
-		if !fieldFound {
-			return errors.New(fmt.Sprintf("invalid field in update where clause :: %s", wc.ColumnName))
-		}
-	}
-	buffer.WriteString(";")
-	// fmt.Printf("update query : %s \n", buffer.String())
-	if err := t.dbSession.Query(buffer.String()).Exec(); err != nil {
+	stmt, values, err := t.updateStatement(updateMap, updateParm, whereClause)
+	if err != nil {
 		return err
 	}
-	return nil
+	return t.conn.exec(t.context(), t.queryInfo(ops.OpUpdate, stmt), values...)
 }
 
 /*
@@ -504,7 +268,7 @@ func (t *Table) ReadAndBind(x interface{}, whereClause []whc.WhereClauseType,
 	log.Printf("select one query : %s", buffer.String())
 
 	resultMap := make(map[string]interface{})
-	if err := t.dbSession.Query(buffer.String()).MapScan(resultMap); err != nil {
+	if err := t.conn.session().Query(buffer.String()).MapScan(resultMap); err != nil {
 		return err
 	}
 
@@ -543,22 +307,25 @@ func (t *Table) ReadAndBind(x interface{}, whereClause []whc.WhereClauseType,
 func (t *Table) ReadAndBind(x interface{}, whereClause []whc.WhereClauseType,
 	groupByClause []string, orderByClause map[string]string) error {
 
-	buffer, _ := getReadQueryString(t.entities, t.KeySpace, t.Name, whereClause,
-		groupByClause, orderByClause)
-	log.Printf("select one query : %s", buffer.String())
+	cols, err := t.projection(ops.OpRead)
+	if err != nil {
+		return err
+	}
+	stmt, values, err := t.readStatement(ops.OpRead, selectList(cols), whereClause, groupByClause, orderByClause)
+	if err != nil {
+		return err
+	}
 
-	// resultMap := make(map[string]interface{})
-	xv := reflect.ValueOf(x).Elem() 
-	xt := xv.Type()
-	var args []interface{}
-	for i := 0; i < xt.NumField(); i++ {
-		addr := xv.Field(i).Addr().Interface()
-		args = append(args, addr)
+	xv := reflect.ValueOf(x).Elem()
+	args := t.scanTargets(xv, cols)
+	if err := t.conn.scan(t.context(), t.queryInfo(ops.OpRead, stmt), values, args...); err != nil {
+		return err
 	}
-	if err := t.dbSession.Query(buffer.String()).Scan(args...); err != nil {
+	if err := t.decryptTargets(ops.OpRead, xv, cols, args); err != nil {
 		return err
 	}
-	return nil
+	setFieldMask(xv, cols, scannedValue(args))
+	return t.afterRead(ops.OpRead, xv)
 }
 
 /*
@@ -570,7 +337,7 @@ func (t *Table) Read(whereClause []whc.WhereClauseType, groupByClause []string,
 	log.Printf("select one query : %s", buffer.String())
 
 	resultMap := make(map[string]interface{})
-	if err := t.dbSession.Query(buffer.String()).MapScan(resultMap); err != nil {
+	if err := t.conn.session().Query(buffer.String()).MapScan(resultMap); err != nil {
 		return nil, err
 	}
 	// fmt.Printf("MapScan result : %v\n", resultMap)
@@ -616,27 +383,27 @@ func (t *Table) Read(whereClause []whc.WhereClauseType, groupByClause []string,
 func (t *Table) Read(whereClause []whc.WhereClauseType, groupByClause []string,
 	orderByClause map[string]string) (interface{}, error) {
 
-	buffer, _ := getReadQueryString(t.entities, t.KeySpace, t.Name, whereClause,
-		groupByClause, orderByClause)
-	log.Printf("select one query : %s", buffer.String())
-
-	resultMap := make(map[string]interface{})
-	if err := t.dbSession.Query(buffer.String()).MapScan(resultMap); err != nil {
+	cols, err := t.projection(ops.OpRead)
+	if err != nil {
+		return nil, err
+	}
+	stmt, values, err := t.readStatement(ops.OpRead, selectList(cols), whereClause, groupByClause, orderByClause)
+	if err != nil {
 		return nil, err
 	}
 
-	xt := reflect.TypeOf(t.dataModel)
-	xv := reflect.New(xt)
-	s := xv.Elem()
-	var args []interface{}
-	for i := 0; i < xt.NumField(); i++ {
-		addr := s.Field(i).Addr().Interface()
-		args = append(args, addr)
+	s := reflect.New(reflect.TypeOf(t.dataModel)).Elem()
+	args := t.scanTargets(s, cols)
+	if err := t.conn.scan(t.context(), t.queryInfo(ops.OpRead, stmt), values, args...); err != nil {
+		return nil, err
 	}
-	if err := t.dbSession.Query(buffer.String()).Scan(args...); err != nil {
+	if err := t.decryptTargets(ops.OpRead, s, cols, args); err != nil {
+		return nil, err
+	}
+	setFieldMask(s, cols, scannedValue(args))
+	if err := t.afterRead(ops.OpRead, s); err != nil {
 		return nil, err
 	}
-	fmt.Printf("query result in var : %v\n", xv)
 	return s.Interface(), nil
 }
 
@@ -645,83 +412,123 @@ func (t *Table) List(whereClause []whc.WhereClauseType,
 	groupByClause []string, orderByClause map[string]string,
 	count int, pageIndex string) (interface{}, error) {
 
-	buffer, _ := getReadQueryString(t.entities, t.KeySpace, t.Name, whereClause,
+	cols, err := t.projection(ops.OpList)
+	if err != nil {
+		return nil, err
+	}
+	whereClause, err = t.encryptWhere(ops.OpList, whereClause)
+	if err != nil {
+		return nil, err
+	}
+	buffer, _ := getReadQueryString(t.entities, selectList(cols), t.KeySpace, t.Name, whereClause,
 		groupByClause, orderByClause)
 	// fmt.Printf("select multiple query : %s\n", buffer.String())
 
-	iter := t.dbSession.Query(buffer.String()).Consistency(gocql.One).Iter()
-	resultMap := make(map[string]interface{})
-
 	many := reflect.New(reflect.SliceOf(reflect.TypeOf(t.dataModel)))
 	manyVals := many.Elem()
 
-	for iter.MapScan(resultMap) {
-		// fmt.Printf("iter result : %v\n", resultMap)
-		typ := reflect.TypeOf(t.dataModel)
-		one := reflect.New(typ)
-		oneVal := one.Elem()
-		for k, v := range resultMap {
-			for _, entity := range t.entities {
-				if entity.columnName == k {
-
-					// check before calling function
-					// fmt.Printf("Field Name :: %s\n", entity.fieldName)
-					structFieldValue := oneVal.FieldByName(entity.fieldName)
-					if !structFieldValue.IsValid() {
-						log.Printf("Field not found, skipping: %s", entity.fieldName)
-						break
-					}
-					// fmt.Printf("Field value :: %v\n", structFieldValue)
-					if !structFieldValue.CanSet() {
-						log.Printf("Cannot set %s field value", entity.fieldName)
-						break
-					}
-					structFieldType := structFieldValue.Type()
-					val := reflect.ValueOf(v)
-					if structFieldType != val.Type() {
-						// fmt.Println("%v : %v", structFieldType, val.Type())
-						log.Printf("Field value type not matching field type")
-						break
-					}
-					structFieldValue.Set(val)
-					// fmt.Printf("value has been set :: %v\n", structFieldValue)
-					break
+	err = t.conn.do(t.context(), t.queryInfo(ops.OpList, buffer.String()), nil,
+		func(q *gocql.Query) (int, error) {
+			iter := q.Iter()
+			resultMap := make(map[string]interface{})
+			rows := 0
+			for iter.MapScan(resultMap) {
+				// fmt.Printf("iter result : %v\n", resultMap)
+				row, err := t.rowFromMap(ops.OpList, resultMap)
+				if err != nil {
+					iter.Close()
+					return rows, err
 				}
+				setFieldMask(row, cols, mappedValue(resultMap))
+				manyVals.Set(reflect.Append(manyVals, row))
+				resultMap = make(map[string]interface{})
+				rows++
 			}
-		}
-		// fmt.Println("before adding to slice...")
-		manyVals.Set(reflect.Append(manyVals, oneVal))
-		// fmt.Println("after adding to slice...")
-		resultMap = make(map[string]interface{})
-	}
-	if err := iter.Close(); err != nil {
+			return rows, iter.Close()
+		})
+	if err != nil {
 		// fmt.Printf("err in iter query : %v", err)
-		if err == gocql.ErrNotFound {
+		if errors.Is(err, ops.ErrNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
+	for i := 0; i < manyVals.Len(); i++ {
+		if err := t.afterRead(ops.OpList, manyVals.Index(i)); err != nil {
+			return nil, err
+		}
+	}
 	// fmt.Printf("Multi result %v\n", manyVals)
 	return manyVals.Interface(), nil
 }
 
+// rowFromMap builds a value of the table data model from a row returned by
+// MapScan, decrypting the encrypted columns for op.
+func (t *Table) rowFromMap(op string, resultMap map[string]interface{}) (reflect.Value, error) {
+	typ := reflect.TypeOf(t.dataModel)
+	one := reflect.New(typ)
+	oneVal := one.Elem()
+	for k, v := range resultMap {
+		for _, entity := range t.entities {
+			if entity.columnName == k {
+
+				// check before calling function
+				// fmt.Printf("Field Name :: %s\n", entity.fieldName)
+				structFieldValue := oneVal.FieldByName(entity.fieldName)
+				if !structFieldValue.IsValid() {
+					t.logField("field not found, skipping", entity.fieldName)
+					break
+				}
+				// fmt.Printf("Field value :: %v\n", structFieldValue)
+				if !structFieldValue.CanSet() {
+					t.logField("cannot set field value", entity.fieldName)
+					break
+				}
+				if sealed, ok := v.([]byte); ok && entity.encrypt != "" {
+					if err := t.decryptValue(op, entity, sealed, structFieldValue); err != nil {
+						return oneVal, err
+					}
+					break
+				}
+				structFieldType := structFieldValue.Type()
+				val := reflect.ValueOf(v)
+				if structFieldType != val.Type() {
+					// fmt.Println("%v : %v", structFieldType, val.Type())
+					t.logField("field value type not matching field type", entity.fieldName)
+					break
+				}
+				structFieldValue.Set(val)
+				// fmt.Printf("value has been set :: %v\n", structFieldValue)
+				break
+			}
+		}
+	}
+	return oneVal, nil
+}
+
+func (t *Table) logField(msg string, fieldName string) {
+	t.conn.log().Log(ops.LevelWarn, msg, ops.F(ops.FieldKeyspace, t.KeySpace),
+		ops.F(ops.FieldTable, t.Name), ops.F("field", fieldName))
+}
+
 func (t *Table) Backup(tableName string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpBackup, Keyspace: t.KeySpace, Table: t.Name, Kind: ops.ErrNoSupport}
 }
 
 func (t *Table) Restore(tableName string) error {
-	return nil
+	return &ops.OpError{Op: ops.OpRestore, Keyspace: t.KeySpace, Table: t.Name, Kind: ops.ErrNoSupport}
 }
 
-func getReadQueryString(entities []Entity, keySpace string, name string,
+// getReadQueryString builds a select statement, columns is the select list.
+func getReadQueryString(entities []Entity, columns []string, keySpace string, name string,
 	whereClause []whc.WhereClauseType, groupByClause []string,
 	orderByClause map[string]string) (bytes.Buffer, error) {
 
 	var buffer bytes.Buffer
 	buffer.WriteString("SELECT ")
-	counter := len(entities)
-	for _, entity := range entities {
-		buffer.WriteString(entity.columnName)
+	counter := len(columns)
+	for _, column := range columns {
+		buffer.WriteString(column)
 		if counter > 1 {
 			buffer.WriteString(", ")
 			counter--
@@ -805,6 +612,9 @@ func checkTypeAndWrite(keyType string, data interface{}) string {
 		buf.WriteString("'")
 		buf.WriteString(fmt.Sprintf("%s", data))
 		buf.WriteString("'")
+	} else if b, ok := data.([]byte); ok && keyType == "blob" {
+		buf.WriteString("0x")
+		buf.WriteString(hex.EncodeToString(b))
 	} else if keyType == "timestamp" {
 		t, ok := data.(time.Time)
 		if !ok {
@@ -819,50 +629,6 @@ func checkTypeAndWrite(keyType string, data interface{}) string {
 	return buf.String()
 }
 
-func getCollectionToBuffer(x interface{}, columnName string, collectionType string) (string, error) {
-
-	var buf bytes.Buffer
-
-	if collectionType == "list" || collectionType == "set" {
-		slice, ok := x.([]string)
-		if !ok {
-			return "", errors.New(fmt.Sprintf("Invalid list or set data format for create or update for column \"%s\"\n", columnName))
-		}
-		first := true
-		for _, v := range slice {
-			if first {
-				first = false
-			} else {
-				buf.WriteString(",")
-			}
-			buf.WriteString("'")
-			buf.WriteString(fmt.Sprintf("%s", v))
-			buf.WriteString("'")
-		}
-	} else {
-		mp, ok := x.(map[string]string)
-		if !ok {
-			return "", errors.New(fmt.Sprintf("Invalid map data format for create or update for column \"%s\"\n", columnName))
-		} else {
-			first := true
-			for k, v := range mp {
-				if first {
-					first = false
-				} else {
-					buf.WriteString(",")
-				}
-				buf.WriteString("'")
-				buf.WriteString(fmt.Sprintf("%s", k))
-				buf.WriteString("' : ")
-				buf.WriteString("'")
-				buf.WriteString(fmt.Sprintf("%s", v))
-				buf.WriteString("'")
-			}
-		}
-	}
-	return buf.String(), nil
-}
-
 func fillStruct(ptr interface{}, m map[string]interface{}, entities []Entity) error {
 
 	t := reflect.TypeOf(ptr)
@@ -948,7 +714,8 @@ func setField(obj interface{}, name string, value interface{}) error {
 	val := reflect.ValueOf(value)
 	if structFieldType != val.Type() {
 		// fmt.Println("%v : %v", structFieldType, val.Type())
-		return errors.New("Provided value type didn't match obj field type")
+		return &ops.OpError{Op: ops.OpRead, Kind: ops.ErrSchemaMismatch,
+			Err: fmt.Errorf("provided value type %s didn't match field %s type %s", val.Type(), name, structFieldType)}
 	}
 	structFieldValue.Set(val)
 	return nil
@@ -0,0 +1,125 @@
+package cassandradb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation name of the spans created by the driver.
+const tracerName = "github.com/meooio/goava/driver/cassandradb"
+
+// span attribute keys, following the OpenTelemetry database conventions
+const (
+	attrDBSystem      = attribute.Key("db.system")
+	attrDBName        = attribute.Key("db.name")
+	attrDBOperation   = attribute.Key("db.operation")
+	attrDBStatement   = attribute.Key("db.statement")
+	attrDBConsistency = attribute.Key("db.cassandra.consistency_level")
+	attrDBTable       = attribute.Key("db.cassandra.table")
+	attrDBPageCount   = attribute.Key("db.cassandra.page_count")
+	attrDBRetryCount  = attribute.Key("db.cassandra.retry_count")
+	attrDBRows        = attribute.Key("db.cassandra.rows")
+	attrCoordinatorID = attribute.Key("db.cassandra.coordinator.id")
+	attrCoordinatorDC = attribute.Key("db.cassandra.coordinator.dc")
+	attrServerAddress = attribute.Key("server.address")
+	attrAttempt       = attribute.Key("db.cassandra.attempt")
+)
+
+var noopTracer trace.Tracer = noop.NewTracerProvider().Tracer(tracerName)
+
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		return noopTracer
+	}
+	return tp.Tracer(tracerName)
+}
+
+// startSpan starts the span of an operation on keyspace and table. The span
+// name is the operation followed by the table it runs on.
+func (cn *conn) startSpan(ctx context.Context, op, keyspace, table string) (context.Context, trace.Span) {
+	name := op
+	if table != "" {
+		name += " " + keyspace + "." + table
+	} else if keyspace != "" {
+		name += " " + keyspace
+	}
+	attrs := []attribute.KeyValue{
+		attrDBSystem.String("cassandra"),
+		attrDBOperation.String(op),
+	}
+	if keyspace != "" {
+		attrs = append(attrs, attrDBName.String(keyspace))
+	}
+	if table != "" {
+		attrs = append(attrs, attrDBTable.String(table))
+	}
+	return cn.tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of a statement on span and ends it.
+func endSpan(span trace.Span, result ops.QueryResult, err error) {
+	span.SetAttributes(
+		attrDBRows.Int(result.Rows),
+		attrDBPageCount.Int(result.Pages),
+		attrDBRetryCount.Int(result.Retries),
+	)
+	spanError(span, err)
+	span.End()
+}
+
+// spanError marks span as failed with err. Rows that were not found are
+// not reported as errors.
+func spanError(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ops.ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// traceCall runs fn in a span covering an operation made of several
+// statements, each of which gets a span of its own below it.
+func (cn *conn) traceCall(ctx context.Context, op, keyspace, table string, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := cn.startSpan(ctx, op, keyspace, table)
+	err := fn(ctx)
+	spanError(span, err)
+	span.End()
+	return err
+}
+
+func consistencyName(c gocql.Consistency) string {
+	return strings.ToLower(c.String())
+}
+
+// traceAttempt adds an event for a single attempt of a statement to span,
+// carrying the coordinator that served it and whether it was a retry.
+func traceAttempt(span trace.Span, q gocql.ObservedQuery) {
+	if !span.IsRecording() {
+		return
+	}
+	name := "coordinator"
+	if q.Attempt > 0 {
+		name = "retry"
+	}
+	attrs := []attribute.KeyValue{attrAttempt.Int(q.Attempt)}
+	if q.Host != nil {
+		attrs = append(attrs,
+			attrServerAddress.String(q.Host.ConnectAddressAndPort()),
+			attrCoordinatorID.String(q.Host.HostID()),
+			attrCoordinatorDC.String(q.Host.DataCenter()))
+	}
+	if q.Err != nil {
+		attrs = append(attrs, attribute.String("error", q.Err.Error()))
+	}
+	span.AddEvent(name, trace.WithTimestamp(q.Start), trace.WithAttributes(attrs...))
+}
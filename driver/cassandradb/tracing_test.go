@@ -0,0 +1,108 @@
+package cassandradb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tracedUser struct {
+	ID   string `cql:"column_name=id,primary_key=0"`
+	Name string `cql:"column_name=name"`
+}
+
+func newTracedConn() (*conn, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cn := newConn(nil)
+	cn.setTracerProvider(tp)
+	return cn, exporter
+}
+
+func spanAttrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestStatementSpan(t *testing.T) {
+	cn, exporter := newTracedConn()
+	info := ops.QueryInfo{Operation: ops.OpRead, Keyspace: "ks", Table: "users",
+		Statement: "SELECT * FROM ks.users WHERE id = 'secret'"}
+
+	// without a session the statement fails before it reaches the cluster
+	err := cn.exec(context.Background(), info)
+	require.ErrorIs(t, err, ops.ErrUnavailable)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "read ks.users", span.Name)
+	assert.Equal(t, codes.Error, span.Status.Code)
+
+	attrs := spanAttrs(span)
+	assert.Equal(t, "cassandra", attrs[attrDBSystem].AsString())
+	assert.Equal(t, "ks", attrs[attrDBName].AsString())
+	assert.Equal(t, ops.OpRead, attrs[attrDBOperation].AsString())
+	assert.Equal(t, "users", attrs[attrDBTable].AsString())
+	assert.Equal(t, "SELECT * FROM ks.users WHERE id = ?", attrs[attrDBStatement].AsString())
+
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "exception", span.Events[0].Name)
+}
+
+func TestNotFoundIsNotASpanError(t *testing.T) {
+	cn, exporter := newTracedConn()
+	ctx, span := cn.startSpan(context.Background(), ops.OpRead, "ks", "users")
+	assert.True(t, span.SpanContext().IsValid())
+	assert.NotNil(t, ctx)
+	endSpan(span, ops.QueryResult{}, wrapError(ops.QueryInfo{Operation: ops.OpRead}, gocql.ErrNotFound))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+}
+
+func TestAttemptEvents(t *testing.T) {
+	cn, exporter := newTracedConn()
+	_, span := cn.startSpan(context.Background(), ops.OpInsert, "ks", "users")
+	obs := &statementObserver{span: span}
+	start := time.Now()
+	obs.ObserveQuery(context.Background(), gocql.ObservedQuery{Start: start, Attempt: 0, Err: gocql.ErrTimeoutNoResponse})
+	obs.ObserveQuery(context.Background(), gocql.ObservedQuery{Start: start, Attempt: 1})
+	endSpan(span, ops.QueryResult{Pages: int(obs.pages), Retries: int(obs.retries)}, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	events := spans[0].Events
+	require.Len(t, events, 2)
+	assert.Equal(t, "coordinator", events[0].Name)
+	assert.Equal(t, "retry", events[1].Name)
+	assert.Equal(t, int64(1), spanAttrs(spans[0])[attrDBRetryCount].AsInt64())
+}
+
+func TestCreateTableSpans(t *testing.T) {
+	cn, exporter := newTracedConn()
+	ks := newKeySpace("ks", cn)
+	_, err := ks.CreateTable("users", tracedUser{})
+	require.Error(t, err)
+
+	// without a session the existence check fails before any statement
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	parent := spans[0]
+	assert.Equal(t, "create_table ks.users", parent.Name)
+	assert.Equal(t, codes.Error, parent.Status.Code)
+	assert.Contains(t, parent.Status.Description, "table_exists")
+}
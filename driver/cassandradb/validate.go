@@ -0,0 +1,252 @@
+package cassandradb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+)
+
+// Name of the struct tag holding the validation rules of a column, a comma
+// separated list of:
+//
+//	required     the value is not the zero value of its type
+//	min=n max=n  numbers are at least or at most n, strings, collections
+//	             and blobs have at least or at most n elements
+//	len=n        strings, collections and blobs have exactly n elements
+//	oneof=a b c  the value is one of the space separated values
+//	regex=expr   strings match expr, it takes the rest of the tag
+//
+// Nil pointers only fail required. Key columns that can be empty, strings,
+// blobs, collections and pointers, are always required.
+const validateTagName = "validate"
+
+// rule is one validation rule of a column.
+type rule struct {
+	name  string
+	arg   string
+	num   float64
+	re    *regexp.Regexp
+	oneof []string
+}
+
+func (r rule) String() string {
+	if r.arg == "" {
+		return r.name
+	}
+	return r.name + "=" + r.arg
+}
+
+var requiredRule = rule{name: "required"}
+
+// parseRules parses the validate tag of field.
+func parseRules(field reflect.StructField) ([]rule, error) {
+	tag := field.Tag.Get(validateTagName)
+	if tag == "" {
+		return nil, nil
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	invalid := func(detail string) error {
+		return CassandraDBError{time.Now(), "invalid validate tag on " + field.Name, detail}
+	}
+
+	var rules []rule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		r := rule{name: strings.TrimSpace(part)}
+		if i := strings.Index(part, "="); i >= 0 {
+			r.name, r.arg = strings.TrimSpace(part[:i]), part[i+1:]
+		}
+
+		switch r.name {
+		case "required":
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(r.arg, 64)
+			if err != nil {
+				return nil, invalid(part)
+			}
+			r.num = n
+			if _, ok := ruleSize(reflect.Zero(typ)); !ok {
+				return nil, invalid(r.name + " on " + typ.String())
+			}
+		case "regex":
+			re, err := regexp.Compile(r.arg)
+			if err != nil {
+				return nil, invalid(err.Error())
+			}
+			r.re = re
+			if typ.Kind() != reflect.String {
+				return nil, invalid("regex on " + typ.String())
+			}
+		case "oneof":
+			r.oneof = strings.Fields(r.arg)
+			if len(r.oneof) == 0 {
+				return nil, invalid(part)
+			}
+			switch typ.Kind() {
+			case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return nil, invalid("oneof on " + typ.String())
+			}
+		default:
+			return nil, invalid(part)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// keyRules adds the required rule to the rules of a key column whose values
+// can be empty.
+func keyRules(field reflect.StructField, rules []rule) []rule {
+	switch field.Type.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+	default:
+		return rules
+	}
+	for _, r := range rules {
+		if r.name == "required" {
+			return rules
+		}
+	}
+	return append([]rule{requiredRule}, rules...)
+}
+
+// ruleSize returns the number min, max and len compare against, the value
+// of numbers and the length of strings, collections and blobs.
+func ruleSize(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+// check reports whether v passes the rule.
+func (r rule) check(v reflect.Value) bool {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return r.name != "required"
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return r.name != "required"
+	}
+	switch r.name {
+	case "required":
+		return !v.IsZero()
+	case "min", "max", "len":
+		size, ok := ruleSize(v)
+		switch {
+		case !ok:
+			return false
+		case r.name == "min":
+			return size >= r.num
+		case r.name == "max":
+			return size <= r.num
+		}
+		return size == r.num
+	case "regex":
+		return v.Kind() == reflect.String && r.re.MatchString(v.String())
+	case "oneof":
+		if _, ok := ruleSize(v); !ok || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			return false
+		}
+		s := fmt.Sprint(v.Interface())
+		for _, option := range r.oneof {
+			if s == option {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// checkColumn appends the rules of entity that v fails to errs.
+func checkColumn(errs []ops.FieldViolation, entity Entity, v reflect.Value) []ops.FieldViolation {
+	for _, r := range entity.rules {
+		if !r.check(v) {
+			errs = append(errs, ops.FieldViolation{Field: entity.fieldName, Column: entity.columnName, Rule: r.String()})
+		}
+	}
+	return errs
+}
+
+// validationError returns the error of a write whose values failed
+// validation, nil when errs is empty.
+func (t *Table) validationError(op string, errs []ops.FieldViolation) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ops.OpError{Op: op, Keyspace: t.KeySpace, Table: t.Name, Kind: ops.ErrInvalidQuery,
+		Err: &ops.ValidationError{Fields: errs}}
+}
+
+// validateRow checks every column of row, a struct of the data model.
+func (t *Table) validateRow(op string, row reflect.Value) error {
+	var errs []ops.FieldViolation
+	for _, entity := range t.entities {
+		if len(entity.rules) > 0 {
+			errs = checkColumn(errs, entity, row.FieldByName(entity.fieldName))
+		}
+	}
+	return t.validationError(op, errs)
+}
+
+// validateUpdate checks the values set by an update and the key values of
+// its where clause. Collection additions and removals and counter deltas
+// are not checked.
+func (t *Table) validateUpdate(updateMap map[string]interface{}, whereClause []whc.WhereClauseType) error {
+	var errs []ops.FieldViolation
+	for _, entity := range t.entities {
+		if len(entity.rules) == 0 {
+			continue
+		}
+		value, ok := updateMap[entity.columnName]
+		if !ok {
+			continue
+		}
+		if entity.columnType == "collection" || entity.columnType == "counter" {
+			op, ok := value.([]interface{})
+			if !ok || len(op) != 2 || op[0] != "all" {
+				continue
+			}
+			value = op[1]
+		}
+		errs = checkColumn(errs, entity, reflect.ValueOf(value))
+	}
+	for _, wc := range whereClause {
+		entity, ok := t.entity(wc.ColumnName)
+		if ok && (entity.primaryKey || entity.clusteringKey) && wc.RelationType == "=" {
+			errs = checkColumn(errs, entity, reflect.ValueOf(wc.ColumnValue))
+		}
+	}
+	return t.validationError(ops.OpUpdate, errs)
+}
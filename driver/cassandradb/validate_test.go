@@ -0,0 +1,115 @@
+package cassandradb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/meooio/goava/whc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validatedAccount struct {
+	Tenant string            `cql:"column_name=tenant,primary_key=0"`
+	ID     int               `cql:"column_name=id,clustering_key=0"`
+	Email  string            `cql:"column_name=email" validate:"required,max=20,regex=^[a-z]+@[a-z]+\\.[a-z]{2,3}$"`
+	Age    int               `cql:"column_name=age" validate:"min=18,max=130"`
+	Plan   string            `cql:"column_name=plan" validate:"oneof=free pro"`
+	Code   *string           `cql:"column_name=code" validate:"len=4"`
+	Tags   []string          `cql:"column_name=tags,column_type=collection,column_subtype=set,column_valuetype=text" validate:"max=2"`
+	Attrs  map[string]string `cql:"column_name=attrs,column_type=collection,column_subtype=map,column_keytype=text,column_valuetype=text"`
+}
+
+func validAccount() validatedAccount {
+	return validatedAccount{Tenant: "acme", ID: 1, Email: "ann@acme.io", Age: 30, Plan: "pro"}
+}
+
+func validationFields(t *testing.T, err error) []string {
+	require.ErrorIs(t, err, ops.ErrInvalidQuery)
+	var verr *ops.ValidationError
+	require.True(t, errors.As(err, &verr), err)
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.String())
+	}
+	return fields
+}
+
+func TestValidateInsert(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, validatedAccount{})
+
+	assert.ErrorIs(t, tbl.Insert(validAccount()), ops.ErrUnavailable)
+	code := "ab12"
+	row := validAccount()
+	row.Code = &code
+	row.Tags = []string{"a", "b"}
+	assert.ErrorIs(t, tbl.Insert(&row), ops.ErrUnavailable)
+	require.Len(t, *stmts, 2)
+
+	short := "abc"
+	bad := validatedAccount{Email: "Not An Email Address At All", Age: 12, Plan: "gold", Code: &short,
+		Tags: []string{"a", "b", "c"}}
+	err := tbl.Insert(bad)
+	assert.Equal(t, []string{"tenant failed required", "email failed max=20",
+		"email failed regex=^[a-z]+@[a-z]+\\.[a-z]{2,3}$", "age failed min=18", "plan failed oneof=free pro",
+		"code failed len=4", "tags failed max=2"}, validationFields(t, err))
+	assert.ErrorIs(t, tbl.InsertIfNotExists(bad), ops.ErrInvalidQuery)
+	assert.Len(t, *stmts, 2)
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tbl, stmts := newRecordingTable(t, validatedAccount{})
+
+	assert.ErrorIs(t, tbl.Update(validAccount()), ops.ErrUnavailable)
+	bad := validAccount()
+	bad.Tenant = ""
+	bad.Email = ""
+	assert.Equal(t, []string{"tenant failed required", "email failed required",
+		"email failed regex=^[a-z]+@[a-z]+\\.[a-z]{2,3}$"}, validationFields(t, tbl.Update(bad)))
+
+	where := []whc.WhereClauseType{{ColumnName: "tenant", RelationType: "=", ColumnValue: "acme"},
+		{ColumnName: "id", RelationType: "=", ColumnValue: 1}}
+	assert.ErrorIs(t, tbl.UpdateFields(map[string]interface{}{"age": 40,
+		"tags": []interface{}{"add", []string{"x", "y", "z"}}}, nil, where), ops.ErrUnavailable)
+	assert.Equal(t, []string{"age failed min=18", "tags failed max=2"},
+		validationFields(t, tbl.UpdateFields(map[string]interface{}{"age": 3,
+			"tags": []interface{}{"all", []string{"x", "y", "z"}}}, nil, where)))
+
+	where[0].ColumnValue = ""
+	assert.Equal(t, []string{"tenant failed required"},
+		validationFields(t, tbl.UpdateFields(map[string]interface{}{"age": 40}, nil, where)))
+	assert.Len(t, *stmts, 2)
+}
+
+func TestValidateTags(t *testing.T) {
+	entities, err := CreateEntity(validatedAccount{})
+	require.NoError(t, err)
+	assert.Equal(t, "required", entities[0].rules[0].String())
+	assert.Empty(t, entities[1].rules)
+	assert.Equal(t, "regex=^[a-z]+@[a-z]+\\.[a-z]{2,3}$", entities[2].rules[2].String())
+
+	for _, model := range []interface{}{
+		struct {
+			A string `cql:"column_name=a,primary_key=0" validate:"min=x"`
+		}{},
+		struct {
+			A int `cql:"column_name=a,primary_key=0" validate:"regex=^a$"`
+		}{},
+		struct {
+			A string `cql:"column_name=a,primary_key=0" validate:"regex=("`
+		}{},
+		struct {
+			A bool `cql:"column_name=a,primary_key=0" validate:"max=1"`
+		}{},
+		struct {
+			A float64 `cql:"column_name=a,primary_key=0" validate:"oneof=1 2"`
+		}{},
+		struct {
+			A string `cql:"column_name=a,primary_key=0" validate:"unique"`
+		}{},
+	} {
+		_, err := CreateEntity(model)
+		assert.Error(t, err, "%T", model)
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"expvar"
+
+	"github.com/meooio/goava/ops"
+)
+
+// Expvar reports metrics into an expvar.Map. Every series is stored under
+// its name followed by its labels, e.g. goava_queries_total{operation="read"}.
+// Histograms are stored as a _count and a _sum entry.
+type Expvar struct {
+	m *expvar.Map
+}
+
+// NewExpvar returns an Expvar reporting into m.
+func NewExpvar(m *expvar.Map) *Expvar {
+	return &Expvar{m: m}
+}
+
+// PublishExpvar publishes a new map under name and returns an Expvar
+// reporting into it. Like expvar.Publish it panics if name is already used.
+func PublishExpvar(name string) *Expvar {
+	return NewExpvar(expvar.NewMap(name))
+}
+
+// Map returns the map the metrics are reported into.
+func (e *Expvar) Map() *expvar.Map {
+	return e.m
+}
+
+// Add implements ops.Metrics.
+func (e *Expvar) Add(name string, labels ops.Labels, delta float64) {
+	e.m.AddFloat(name+labelString(labels), delta)
+}
+
+// Set implements ops.Metrics.
+func (e *Expvar) Set(name string, labels ops.Labels, value float64) {
+	key := name + labelString(labels)
+	if v, ok := e.m.Get(key).(*expvar.Float); ok {
+		v.Set(value)
+		return
+	}
+	v := new(expvar.Float)
+	v.Set(value)
+	e.m.Set(key, v)
+}
+
+// Observe implements ops.Metrics.
+func (e *Expvar) Observe(name string, labels ops.Labels, value float64) {
+	key := labelString(labels)
+	e.m.AddFloat(name+"_count"+key, 1)
+	e.m.AddFloat(name+"_sum"+key, value)
+}
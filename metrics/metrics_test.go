@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meooio/goava/ops"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ ops.Metrics = (*Prometheus)(nil)
+var _ ops.Metrics = (*Expvar)(nil)
+
+func TestPrometheusText(t *testing.T) {
+	p := NewPrometheusWithBuckets([]float64{0.1, 1})
+	labels := ops.Labels{ops.LabelOperation: "read", ops.LabelTable: "users"}
+	p.Add(ops.MetricQueries, labels, 1)
+	p.Add(ops.MetricQueries, labels, 2)
+	p.Observe(ops.MetricQueryDuration, labels, 0.05)
+	p.Observe(ops.MetricQueryDuration, labels, 0.5)
+	p.Observe(ops.MetricQueryDuration, labels, 3)
+	p.Set(ops.MetricConnState, ops.Labels{ops.LabelState: "connected"}, 1)
+	p.Set(ops.MetricConnState, ops.Labels{ops.LabelState: "connected"}, 0)
+	p.Add("custom_total", ops.Labels{"v": `a"b`}, 1)
+
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	want := `# TYPE custom_total counter
+custom_total{v="a\"b"} 1
+# HELP goava_connection_state 1 for the current connection state of the client, 0 otherwise.
+# TYPE goava_connection_state gauge
+goava_connection_state{state="connected"} 0
+# HELP goava_queries_total Statements executed, by operation and table.
+# TYPE goava_queries_total counter
+goava_queries_total{operation="read",table="users"} 3
+# HELP goava_query_duration_seconds Statement latency in seconds, by operation and table.
+# TYPE goava_query_duration_seconds histogram
+goava_query_duration_seconds_bucket{le="0.1",operation="read",table="users"} 1
+goava_query_duration_seconds_bucket{le="1",operation="read",table="users"} 2
+goava_query_duration_seconds_bucket{le="+Inf",operation="read",table="users"} 3
+goava_query_duration_seconds_sum{operation="read",table="users"} 3.55
+goava_query_duration_seconds_count{operation="read",table="users"} 3
+`
+	assert.Equal(t, want, buf.String())
+	assert.Equal(t, 3.0, p.Value(ops.MetricQueries, labels))
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	p := NewPrometheus()
+	p.Add(ops.MetricReconnects, nil, 1)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, rec.Body.String(), "goava_reconnects_total 1\n")
+}
+
+func TestExpvar(t *testing.T) {
+	e := NewExpvar(new(expvar.Map).Init())
+	labels := ops.Labels{ops.LabelOperation: "insert"}
+	e.Add(ops.MetricQueries, labels, 1)
+	e.Add(ops.MetricQueries, labels, 1)
+	e.Observe(ops.MetricQueryDuration, labels, 0.25)
+	e.Set(ops.MetricPoolHosts, nil, 3)
+	e.Set(ops.MetricPoolHosts, nil, 2)
+
+	m := e.Map()
+	assert.Equal(t, "2", m.Get(`goava_queries_total{operation="insert"}`).String())
+	assert.Equal(t, "1", m.Get(`goava_query_duration_seconds_count{operation="insert"}`).String())
+	assert.Equal(t, "0.25", m.Get(`goava_query_duration_seconds_sum{operation="insert"}`).String())
+	assert.Equal(t, "2", m.Get(ops.MetricPoolHosts).String())
+}
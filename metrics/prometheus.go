@@ -0,0 +1,253 @@
+// Package metrics provides ops.Metrics implementations that expose the
+// measurements taken by the drivers.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/meooio/goava/ops"
+)
+
+// DefaultBuckets are the histogram upper bounds, in seconds, used by
+// NewPrometheus.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+type series struct {
+	labels ops.Labels
+	value  float64
+	// histogram state, counts[i] is the number of samples <= buckets[i]
+	counts []uint64
+	count  uint64
+}
+
+type family struct {
+	kind   string
+	series map[string]*series
+}
+
+// Prometheus collects metrics in memory and writes them in the Prometheus
+// text exposition format. It is safe for concurrent use.
+type Prometheus struct {
+	sync.Mutex
+	buckets  []float64
+	families map[string]*family
+}
+
+// NewPrometheus returns a Prometheus collector using DefaultBuckets.
+func NewPrometheus() *Prometheus {
+	return NewPrometheusWithBuckets(DefaultBuckets)
+}
+
+// NewPrometheusWithBuckets returns a Prometheus collector whose histograms
+// use the given upper bounds.
+func NewPrometheusWithBuckets(buckets []float64) *Prometheus {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	return &Prometheus{buckets: b, families: make(map[string]*family)}
+}
+
+func (p *Prometheus) get(name, kind string, labels ops.Labels) *series {
+	f, ok := p.families[name]
+	if !ok {
+		f = &family{kind: kind, series: make(map[string]*series)}
+		p.families[name] = f
+	}
+	key := labelString(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: copyLabels(labels)}
+		if kind == kindHistogram {
+			s.counts = make([]uint64, len(p.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add implements ops.Metrics.
+func (p *Prometheus) Add(name string, labels ops.Labels, delta float64) {
+	p.Lock()
+	defer p.Unlock()
+	p.get(name, kindCounter, labels).value += delta
+}
+
+// Set implements ops.Metrics.
+func (p *Prometheus) Set(name string, labels ops.Labels, value float64) {
+	p.Lock()
+	defer p.Unlock()
+	p.get(name, kindGauge, labels).value = value
+}
+
+// Observe implements ops.Metrics.
+func (p *Prometheus) Observe(name string, labels ops.Labels, value float64) {
+	p.Lock()
+	defer p.Unlock()
+	s := p.get(name, kindHistogram, labels)
+	if s.counts == nil {
+		// the name was first used as a counter or gauge
+		return
+	}
+	for i, bound := range p.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// Value returns the current value of a counter or gauge, or the sum of a
+// histogram. It returns 0 for unknown series.
+func (p *Prometheus) Value(name string, labels ops.Labels) float64 {
+	p.Lock()
+	defer p.Unlock()
+	f, ok := p.families[name]
+	if !ok {
+		return 0
+	}
+	s, ok := f.series[labelString(labels)]
+	if !ok {
+		return 0
+	}
+	return s.value
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by
+// name and labels.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.Lock()
+	defer p.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := p.families[name]
+		if help, ok := ops.MetricHelp[name]; ok {
+			fmt.Fprintf(cw, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(cw, "%s%s %s\n", name, key, formatFloat(s.value))
+				continue
+			}
+			for i, bound := range p.buckets {
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", formatFloat(bound)), s.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(cw, "%s_sum%s %s\n", name, key, formatFloat(s.value))
+			fmt.Fprintf(cw, "%s_count%s %d\n", name, key, s.count)
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func copyLabels(labels ops.Labels) ops.Labels {
+	out := make(ops.Labels, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+func withLabel(labels ops.Labels, key, value string) string {
+	l := copyLabels(labels)
+	l[key] = value
+	return labelString(l)
+}
+
+// labelString formats labels as {a="1",b="2"} sorted by name, empty labels
+// are left out.
+func labelString(labels ops.Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,92 @@
+package ops
+
+import (
+	"strings"
+
+	"github.com/meooio/goava/whc"
+)
+
+// AggFunc is an aggregate function.
+type AggFunc string
+
+const (
+	AggCount AggFunc = "COUNT"
+	AggSum   AggFunc = "SUM"
+	AggMin   AggFunc = "MIN"
+	AggMax   AggFunc = "MAX"
+	AggAvg   AggFunc = "AVG"
+)
+
+// Aggregation is a single aggregate in an aggregate query. Column may be
+// empty for AggCount, which then counts rows. Alias names the value in the
+// result and defaults to the function followed by the column, e.g. "sum_age",
+// or "count" when counting rows.
+type Aggregation struct {
+	Func   AggFunc
+	Column string
+	Alias  string
+}
+
+// Name returns the name of the aggregate in an AggregateRow.
+func (a Aggregation) Name() string {
+	if a.Alias != "" {
+		return strings.ToLower(a.Alias)
+	}
+	name := strings.ToLower(string(a.Func))
+	if a.Column != "" && a.Column != "*" {
+		name += "_" + strings.ToLower(a.Column)
+	}
+	return name
+}
+
+// AggregateRow is the result of an aggregate query for one group.
+type AggregateRow struct {
+	// Group holds the values of the group by columns, it is empty without
+	// a group by.
+	Group map[string]interface{}
+	// Values holds the aggregates by name, typed as the database returned
+	// them: counts are int64, the other functions have the type of their
+	// column.
+	Values map[string]interface{}
+}
+
+// Int64 returns the aggregate name converted to an int64. It reports false
+// when the aggregate is missing or not an integer.
+func (r AggregateRow) Int64(name string) (int64, bool) {
+	switch v := r.Values[strings.ToLower(name)].(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Float64 returns the aggregate name converted to a float64. It reports
+// false when the aggregate is missing or not a number.
+func (r AggregateRow) Float64(name string) (float64, bool) {
+	switch v := r.Values[strings.ToLower(name)].(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	n, ok := r.Int64(name)
+	return float64(n), ok
+}
+
+// Aggregator is implemented by tables that can compute aggregates in the
+// database.
+type Aggregator interface {
+	// Count returns the number of rows matching whereClause.
+	Count(whereClause []whc.WhereClauseType) (int64, error)
+	// Aggregate computes spec over the rows matching whereClause, one row
+	// per group of groupBy.
+	Aggregate(spec []Aggregation, whereClause []whc.WhereClauseType, groupBy []string) ([]AggregateRow, error)
+}
@@ -0,0 +1,29 @@
+package ops
+
+import "time"
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerChange describes a state transition of the circuit breaker of an
+// operation class.
+type BreakerChange struct {
+	Class string
+	From  BreakerState
+	To    BreakerState
+	// Reason describes why the breaker changed state
+	Reason string
+	At     time.Time
+}
+
+// BreakerObserver may be implemented by a QueryHook to be told about the
+// state transitions of the circuit breakers of the client.
+type BreakerObserver interface {
+	BreakerStateChanged(change BreakerChange)
+}
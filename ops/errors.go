@@ -1,6 +1,9 @@
 package ops
 
-import ()
+import (
+	"fmt"
+	"strings"
+)
 
 
 // DatabaseError represents error.
@@ -16,3 +19,69 @@ var (
 	ErrInvalidKeyspace = &DatabaseError{"keyspace is nil"}
 	ErrTableNA = &DatabaseError{"table not available"}
 )
+
+// Error classes every driver maps its errors to, test with errors.Is
+var (
+	ErrNotFound       = &DatabaseError{"not found"}
+	ErrTimeout        = &DatabaseError{"timeout"}
+	ErrUnavailable    = &DatabaseError{"unavailable"}
+	ErrOverloaded     = &DatabaseError{"overloaded"}
+	ErrInvalidQuery   = &DatabaseError{"invalid query"}
+	ErrSchemaMismatch = &DatabaseError{"schema mismatch"}
+	ErrConflict       = &DatabaseError{"conflict, condition not applied"}
+	ErrNoSupport      = &DatabaseError{"operation not supported"}
+	ErrRateLimited    = &DatabaseError{"rate limited"}
+	ErrCircuitOpen    = &DatabaseError{"circuit breaker open"}
+)
+
+// OpError is returned by the drivers. It records the operation and table
+// that failed, the error class (Kind) and the underlying driver error (Err).
+// errors.Is matches both Kind and Err, errors.As reaches the driver error.
+type OpError struct {
+	Op       string
+	Keyspace string
+	Table    string
+	Kind     error
+	Err      error
+}
+
+func (e *OpError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Table != "" {
+		b.WriteString(" ")
+		if e.Keyspace != "" {
+			b.WriteString(e.Keyspace)
+			b.WriteString(".")
+		}
+		b.WriteString(e.Table)
+	} else if e.Keyspace != "" {
+		b.WriteString(" ")
+		b.WriteString(e.Keyspace)
+	}
+	if e.Kind != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Kind.Error())
+	}
+	if e.Err != nil && e.Err != e.Kind {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *OpError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// NewOpError returns an OpError of the given kind whose cause is built from format.
+func NewOpError(op, keyspace, table string, kind error, format string, args ...interface{}) *OpError {
+	return &OpError{Op: op, Keyspace: keyspace, Table: table, Kind: kind, Err: fmt.Errorf(format, args...)}
+}
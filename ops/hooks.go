@@ -0,0 +1,59 @@
+package ops
+
+import (
+	"context"
+	"time"
+)
+
+// QueryInfo describes a statement a driver is about to execute.
+type QueryInfo struct {
+	Operation string
+	Keyspace  string
+	Table     string
+	Statement string
+	// Values is the number of bound values
+	Values int
+	// Attempt starts at 1 and is incremented when the driver runs the same statement again
+	Attempt int
+}
+
+// QueryResult describes the outcome of a statement.
+type QueryResult struct {
+	Rows int
+	// Pages is the number of result pages fetched, Retries the number of
+	// attempts the driver retried internally.
+	Pages    int
+	Retries  int
+	Duration time.Duration
+}
+
+// QueryHook is called around every statement a driver executes. Hooks run
+// in registration order before the statement and in reverse order after it.
+// Before may return a derived context, which is used for the statement and
+// passed to After. If Before returns an error the statement is not run and
+// the error is returned to the caller; After is still called for the hooks
+// whose Before already ran.
+type QueryHook interface {
+	Before(ctx context.Context, info QueryInfo) (context.Context, error)
+	After(ctx context.Context, info QueryInfo, result QueryResult, err error)
+}
+
+// QueryHookFuncs adapts a pair of functions to the QueryHook interface.
+// Either function may be nil.
+type QueryHookFuncs struct {
+	BeforeFunc func(ctx context.Context, info QueryInfo) (context.Context, error)
+	AfterFunc  func(ctx context.Context, info QueryInfo, result QueryResult, err error)
+}
+
+func (h QueryHookFuncs) Before(ctx context.Context, info QueryInfo) (context.Context, error) {
+	if h.BeforeFunc == nil {
+		return ctx, nil
+	}
+	return h.BeforeFunc(ctx, info)
+}
+
+func (h QueryHookFuncs) After(ctx context.Context, info QueryInfo, result QueryResult, err error) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, info, result, err)
+	}
+}
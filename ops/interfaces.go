@@ -21,6 +21,53 @@ const (
 	DESC = "DESC"
 )
 
+// Operation names reported to loggers by the drivers
+const (
+	OpInsert      = "insert"
+	OpRead        = "read"
+	OpList        = "list"
+	OpUpdate      = "update"
+	OpDelete      = "delete"
+	OpCreateTable = "create_table"
+	OpCreateIndex = "create_index"
+	OpDropTable   = "drop_table"
+	OpTableExists = "table_exists"
+	OpCreateDB    = "create_db"
+	OpDropDB      = "drop_db"
+	OpListDBs     = "list_dbs"
+	OpDBExists    = "db_exists"
+	OpAlterTable  = "alter_table"
+	OpBackup      = "backup"
+	OpRestore     = "restore"
+	OpConnect     = "connect"
+	OpGetDB       = "get_db"
+	OpCount       = "count"
+	OpAggregate   = "aggregate"
+	OpScan        = "scan"
+	OpBackfill    = "backfill"
+	OpBatch       = "batch"
+	OpCopy        = "copy"
+)
+
+// Operation classes, used for separate limits per kind of statement
+const (
+	ClassRead  = "read"
+	ClassWrite = "write"
+	ClassDDL   = "ddl"
+)
+
+// OperationClass returns the class of the operation op, operations that
+// are not known are reads.
+func OperationClass(op string) string {
+	switch op {
+	case OpInsert, OpUpdate, OpDelete, OpBatch:
+		return ClassWrite
+	case OpCreateTable, OpCreateIndex, OpDropTable, OpCreateDB, OpDropDB, OpAlterTable:
+		return ClassDDL
+	}
+	return ClassRead
+}
+
 // Table, interface
 //     Every driver needs to be support these interfaces, some databases may not implement all the functions
 //     functions that are implemented, should return ENoSupport
@@ -54,3 +101,15 @@ type Database interface {
 	AlterTable(tableName string) error
 	GetTable(tableName string) (Table, error)
 }
+
+// Scanner is implemented by the tables of drivers that can read every row
+// of a table, it is what goava.CopyTable needs from its source.
+type Scanner interface {
+	// TableName returns the name of the table
+	TableName() string
+	// DataModel returns the struct value the table was created from
+	DataModel() interface{}
+	// Scan calls fn with every row of the table, a value of the data
+	// model, from up to parallelism goroutines
+	Scan(parallelism int, fn func(row interface{}) error) error
+}
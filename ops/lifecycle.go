@@ -0,0 +1,47 @@
+package ops
+
+import "github.com/meooio/goava/whc"
+
+// Lifecycle hooks a data model can implement. The drivers call them on a
+// pointer to the row, so hooks with a pointer receiver can change it: a
+// row passed to Insert or Update by value is copied first, the changes go
+// to the database but not back to the caller. An error returned by a
+// Before hook aborts the operation, an error returned by an After hook is
+// returned to the caller once the operation has been done.
+
+// BeforeInserter is called before a row is inserted, before validation.
+type BeforeInserter interface {
+	BeforeInsert() error
+}
+
+// AfterInserter is called after a row has been inserted.
+type AfterInserter interface {
+	AfterInsert() error
+}
+
+// BeforeUpdater is called before a row is updated with Update, before
+// validation. UpdateFields has no row and calls no hooks.
+type BeforeUpdater interface {
+	BeforeUpdate() error
+}
+
+// AfterUpdater is called after a row has been updated with Update.
+type AfterUpdater interface {
+	AfterUpdate() error
+}
+
+// BeforeDeleter is called before rows are deleted, on the zero value of
+// the data model, with the where clause of the delete.
+type BeforeDeleter interface {
+	BeforeDelete(whereClause []whc.WhereClauseType) error
+}
+
+// AfterDeleter is called after rows have been deleted, like BeforeDeleter.
+type AfterDeleter interface {
+	AfterDelete(whereClause []whc.WhereClauseType) error
+}
+
+// AfterReader is called on every row read by Read, ReadAndBind and List.
+type AfterReader interface {
+	AfterRead() error
+}
@@ -0,0 +1,111 @@
+package ops
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// common field keys used by the drivers
+const (
+	FieldKeyspace  = "keyspace"
+	FieldTable     = "table"
+	FieldOperation = "operation"
+	FieldDuration  = "duration"
+	FieldStatement = "statement"
+	FieldError     = "error"
+	FieldRows      = "rows"
+	FieldFullScan  = "full_scan"
+	FieldTrace     = "trace"
+)
+
+// Field is a structured key value pair attached to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F is shorthand for creating a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger is implemented by anything the drivers can write log entries to.
+// Drivers check Enabled before building expensive entries.
+type Logger interface {
+	Enabled(level Level) bool
+	Log(level Level, msg string, fields ...Field)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Enabled(Level) bool          { return false }
+func (nopLogger) Log(Level, string, ...Field) {}
+
+// NopLogger discards everything, it is the default logger of every driver.
+var NopLogger Logger = nopLogger{}
+
+// slogLogger adapts a *slog.Logger to the Logger interface.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to l.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func (s *slogLogger) Enabled(level Level) bool {
+	return s.l.Enabled(context.Background(), slogLevel(level))
+}
+
+func (s *slogLogger) Log(level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case time.Duration:
+			attrs = append(attrs, slog.Duration(f.Key, v))
+		case error:
+			attrs = append(attrs, slog.String(f.Key, v.Error()))
+		default:
+			attrs = append(attrs, slog.Any(f.Key, v))
+		}
+	}
+	s.l.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
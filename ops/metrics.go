@@ -0,0 +1,109 @@
+package ops
+
+import "errors"
+
+// Labels are the dimensions of a metric sample.
+type Labels map[string]string
+
+// Metrics receives the measurements taken by the drivers. Implementations
+// must be safe for concurrent use. Adapters live in the metrics package.
+type Metrics interface {
+	// Add increments the counter name by delta.
+	Add(name string, labels Labels, delta float64)
+	// Observe records a sample in the histogram name.
+	Observe(name string, labels Labels, value float64)
+	// Set sets the gauge name to value.
+	Set(name string, labels Labels, value float64)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Add(string, Labels, float64)     {}
+func (nopMetrics) Observe(string, Labels, float64) {}
+func (nopMetrics) Set(string, Labels, float64)     {}
+
+// NopMetrics discards every measurement, it is the default of every driver.
+var NopMetrics Metrics = nopMetrics{}
+
+// metric names reported by the drivers
+const (
+	MetricQueries       = "goava_queries_total"
+	MetricQueryDuration = "goava_query_duration_seconds"
+	MetricQueryErrors   = "goava_query_errors_total"
+	MetricRows          = "goava_rows_returned_total"
+	MetricPages         = "goava_pages_fetched_total"
+	MetricRetries       = "goava_query_retries_total"
+	MetricReconnects    = "goava_reconnects_total"
+	MetricConnState     = "goava_connection_state"
+	MetricPoolHosts     = "goava_pool_hosts"
+	MetricBudgetTokens  = "goava_budget_tokens"
+	MetricBudgetInUse   = "goava_budget_in_flight"
+	MetricBudgetWait    = "goava_budget_wait_seconds"
+	MetricRateLimited   = "goava_rate_limited_total"
+	MetricBreakerState  = "goava_breaker_state"
+	MetricBreakerReject = "goava_breaker_rejected_total"
+	MetricStmtCache     = "goava_statement_cache_total"
+)
+
+// label names used with the metrics above
+const (
+	LabelOperation = "operation"
+	LabelKeyspace  = "keyspace"
+	LabelTable     = "table"
+	LabelClass     = "class"
+	LabelState     = "state"
+	LabelBudget    = "budget"
+	LabelOpClass   = "operation_class"
+	LabelResult    = "result"
+)
+
+// MetricHelp describes the metrics reported by the drivers.
+var MetricHelp = map[string]string{
+	MetricQueries:       "Statements executed, by operation and table.",
+	MetricQueryDuration: "Statement latency in seconds, by operation and table.",
+	MetricQueryErrors:   "Failed statements, by operation, table and error class.",
+	MetricRows:          "Rows returned by read statements.",
+	MetricPages:         "Result pages fetched from the database.",
+	MetricRetries:       "Statement attempts retried by the driver.",
+	MetricReconnects:    "Reconnects of the client session.",
+	MetricConnState:     "1 for the current connection state of the client, 0 otherwise.",
+	MetricPoolHosts:     "Hosts the client holds connections to.",
+	MetricBudgetTokens:  "Tokens left in the rate limit of a budget.",
+	MetricBudgetInUse:   "Statements in flight in a budget.",
+	MetricBudgetWait:    "Time statements waited for a budget in seconds.",
+	MetricRateLimited:   "Statements rejected by a budget.",
+	MetricBreakerState:  "1 for the current circuit breaker state of an operation class, 0 otherwise.",
+	MetricBreakerReject: "Statements rejected by an open circuit breaker.",
+	MetricStmtCache:     "Statement cache lookups of a table, by operation and result (hit or miss).",
+}
+
+// ErrorClass returns a short name for the error class of err, used as a
+// metric label. It returns "" for a nil error and "other" for errors that
+// do not belong to any class.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, ErrOverloaded):
+		return "overloaded"
+	case errors.Is(err, ErrInvalidQuery):
+		return "invalid_query"
+	case errors.Is(err, ErrSchemaMismatch):
+		return "schema_mismatch"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.Is(err, ErrNoSupport):
+		return "no_support"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	}
+	return "other"
+}
@@ -0,0 +1,54 @@
+package ops
+
+import (
+	"strings"
+	"time"
+)
+
+// WriteTime returns the pseudo-column selecting the write time of column,
+// for use in a column projection.
+func WriteTime(column string) string {
+	return "WRITETIME(" + column + ")"
+}
+
+// TTL returns the pseudo-column selecting the remaining time to live of
+// column, for use in a column projection.
+func TTL(column string) string {
+	return "TTL(" + column + ")"
+}
+
+// FieldMask reports what a read loaded into a model. A model receives it
+// by declaring a field of type FieldMask or *FieldMask without a cql tag.
+type FieldMask struct {
+	// Loaded holds the columns that were selected, by column name
+	Loaded map[string]bool
+	// WriteTimes and TTLs hold the values of the WRITETIME and TTL
+	// pseudo-columns that were selected. Columns without a value, or
+	// without a time to live, are left out.
+	WriteTimes map[string]time.Time
+	TTLs       map[string]time.Duration
+}
+
+// IsLoaded reports whether column was selected by the read.
+func (m *FieldMask) IsLoaded(column string) bool {
+	return m != nil && m.Loaded[strings.ToLower(column)]
+}
+
+// WriteTime returns the write time of column, if it was selected.
+func (m *FieldMask) WriteTime(column string) (time.Time, bool) {
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, ok := m.WriteTimes[strings.ToLower(column)]
+	return t, ok
+}
+
+// TTL returns the remaining time to live of column, if it was selected
+// and the column expires.
+func (m *FieldMask) TTL(column string) (time.Duration, bool) {
+	if m == nil {
+		return 0, false
+	}
+	d, ok := m.TTLs[strings.ToLower(column)]
+	return d, ok
+}
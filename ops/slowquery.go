@@ -0,0 +1,152 @@
+package ops
+
+import (
+	"sync"
+	"time"
+)
+
+// SlowQuery describes a statement that ran longer than the slow query
+// threshold of a driver.
+type SlowQuery struct {
+	Operation string
+	Keyspace  string
+	Table     string
+	// Statement has its literal values redacted unless debug query logging is on
+	Statement string
+	Rows      int
+	Duration  time.Duration
+	At        time.Time
+	Err       error
+	// FullScan is set for reads that are not restricted by a WHERE clause
+	// or that need ALLOW FILTERING.
+	FullScan bool
+	// Trace holds the server side events of the statement when tracing of
+	// slow queries is enabled, TraceErr the reason they could not be captured.
+	Trace    []TraceEvent
+	TraceErr error
+}
+
+// TraceEvent is a single step of a traced statement as reported by the
+// database.
+type TraceEvent struct {
+	At       time.Time
+	Source   string
+	Activity string
+	Thread   string
+	// Elapsed is the time since the coordinator received the statement
+	Elapsed time.Duration
+}
+
+// SlowQuerySink receives the slow queries of a driver. Implementations must
+// be safe for concurrent use.
+type SlowQuerySink interface {
+	RecordSlowQuery(q SlowQuery)
+}
+
+// SlowQueryRing keeps the most recent slow queries in memory.
+type SlowQueryRing struct {
+	sync.Mutex
+	buf   []SlowQuery
+	next  int
+	total int
+}
+
+// NewSlowQueryRing returns a ring holding up to size slow queries.
+func NewSlowQueryRing(size int) *SlowQueryRing {
+	if size < 1 {
+		size = 1
+	}
+	return &SlowQueryRing{buf: make([]SlowQuery, 0, size)}
+}
+
+// RecordSlowQuery implements SlowQuerySink, it overwrites the oldest entry
+// once the ring is full.
+func (r *SlowQueryRing) RecordSlowQuery(q SlowQuery) {
+	r.Lock()
+	defer r.Unlock()
+	r.total++
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, q)
+		return
+	}
+	r.buf[r.next] = q
+	r.next = (r.next + 1) % len(r.buf)
+}
+
+// Records returns the slow queries held by the ring, oldest first.
+func (r *SlowQueryRing) Records() []SlowQuery {
+	r.Lock()
+	defer r.Unlock()
+	out := make([]SlowQuery, 0, len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	return append(out, r.buf[:r.next]...)
+}
+
+// Total returns the number of slow queries recorded since the ring was
+// created, including the ones that were overwritten.
+func (r *SlowQueryRing) Total() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.total
+}
+
+// Reset empties the ring.
+func (r *SlowQueryRing) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.buf = r.buf[:0]
+	r.next = 0
+	r.total = 0
+}
+
+// slowQueryLogger writes slow queries to a Logger.
+type slowQueryLogger struct {
+	logger Logger
+}
+
+// NewSlowQueryLogger returns a sink that logs every slow query at warn level.
+func NewSlowQueryLogger(logger Logger) SlowQuerySink {
+	if logger == nil {
+		logger = NopLogger
+	}
+	return slowQueryLogger{logger}
+}
+
+func (s slowQueryLogger) RecordSlowQuery(q SlowQuery) {
+	if !s.logger.Enabled(LevelWarn) {
+		return
+	}
+	fields := []Field{
+		F(FieldOperation, q.Operation),
+		F(FieldKeyspace, q.Keyspace),
+		F(FieldTable, q.Table),
+		F(FieldDuration, q.Duration),
+		F(FieldStatement, q.Statement),
+		F(FieldRows, q.Rows),
+		F(FieldFullScan, q.FullScan),
+	}
+	if q.Err != nil {
+		fields = append(fields, F(FieldError, q.Err))
+	}
+	if len(q.Trace) > 0 {
+		trace := make([]string, len(q.Trace))
+		for i, ev := range q.Trace {
+			trace[i] = ev.Source + " +" + ev.Elapsed.String() + " " + ev.Activity
+		}
+		fields = append(fields, F(FieldTrace, trace))
+	}
+	s.logger.Log(LevelWarn, "slow query", fields...)
+}
+
+// MultiSlowQuerySink passes every slow query to each of sinks.
+func MultiSlowQuerySink(sinks ...SlowQuerySink) SlowQuerySink {
+	return multiSlowQuerySink(append([]SlowQuerySink{}, sinks...))
+}
+
+type multiSlowQuerySink []SlowQuerySink
+
+func (m multiSlowQuerySink) RecordSlowQuery(q SlowQuery) {
+	for _, s := range m {
+		s.RecordSlowQuery(q)
+	}
+}
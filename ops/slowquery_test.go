@@ -0,0 +1,26 @@
+package ops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryRing(t *testing.T) {
+	ring := NewSlowQueryRing(3)
+	for _, table := range []string{"a", "b", "c", "d", "e"} {
+		ring.RecordSlowQuery(SlowQuery{Table: table})
+	}
+
+	var tables []string
+	for _, q := range ring.Records() {
+		tables = append(tables, q.Table)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, tables)
+	assert.Equal(t, 5, ring.Total())
+
+	ring.Reset()
+	assert.Empty(t, ring.Records())
+	ring.RecordSlowQuery(SlowQuery{Table: "f"})
+	assert.Len(t, ring.Records(), 1)
+}
@@ -0,0 +1,31 @@
+package ops
+
+import "strings"
+
+// FieldViolation is a column of a row failing one of its validation rules.
+type FieldViolation struct {
+	// Field is the name of the struct field, Column the name of its column
+	Field  string
+	Column string
+	// Rule is the rule that failed as written in the tag, like "min=1"
+	Rule string
+}
+
+func (v FieldViolation) String() string {
+	return v.Column + " failed " + v.Rule
+}
+
+// ValidationError lists every column of a row that failed validation. The
+// drivers return it as the Err of an OpError of kind ErrInvalidQuery, reach
+// it with errors.As.
+type ValidationError struct {
+	Fields []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.String()
+	}
+	return "validation failed: " + strings.Join(parts, ", ")
+}